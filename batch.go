@@ -0,0 +1,109 @@
+package mt
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Batch records a sequence of operations to run on a Drive. The Drive
+// lock is held for the whole sequence, so no other operation on the Drive
+// can run between them. Each operation is still a separate mt invocation.
+type Batch struct {
+	d   *Drive
+	ops []batchOp
+	err error
+}
+
+type batchOp struct {
+	args []string
+	// output operations have their output collected by Run
+	output bool
+}
+
+// Batch returns an empty Batch for the Drive.
+func (d *Drive) Batch() *Batch {
+	return &Batch{d: d}
+}
+
+func (b *Batch) add(output bool, args ...string) *Batch {
+	b.ops = append(b.ops, batchOp{args: args, output: output})
+	return b
+}
+
+// addCount adds an operation taking a count. Invalid counts are recorded
+// and returned by Run. Spacing operations with a count of 0 are skipped.
+func (b *Batch) addCount(op string, n int64, noop bool) *Batch {
+	if err := checkCount(n); err != nil {
+		if b.err == nil {
+			b.err = errors.Wrap(err, op)
+		}
+		return b
+	}
+	if noop && n == 0 {
+		return b
+	}
+	return b.add(false, op, strconv.FormatInt(n, 10))
+}
+
+// ForwardFiles adds forward space n files to the batch.
+func (b *Batch) ForwardFiles(n int64) *Batch { return b.addCount("fsf", n, true) }
+
+// BackwardFiles adds backward space n files to the batch.
+func (b *Batch) BackwardFiles(n int64) *Batch { return b.addCount("bsf", n, true) }
+
+// PositionToFile adds position to the beginning of the nth file to the batch.
+func (b *Batch) PositionToFile(n int64) *Batch { return b.addCount("asf", n, false) }
+
+// ForwardRecords adds forward space n records to the batch.
+func (b *Batch) ForwardRecords(n int64) *Batch { return b.addCount("fsr", n, true) }
+
+// BackwardRecords adds backward space n records to the batch.
+func (b *Batch) BackwardRecords(n int64) *Batch { return b.addCount("bsr", n, true) }
+
+// SeekTape adds seek to the nth block to the batch.
+func (b *Batch) SeekTape(n int64) *Batch { return b.addCount("seek", n, false) }
+
+// SetPartition adds switch to the nth partition to the batch.
+func (b *Batch) SetPartition(n int64) *Batch { return b.addCount("setpartition", n, false) }
+
+// WriteEOFMarks adds write n EOF marks to the batch.
+func (b *Batch) WriteEOFMarks(n int64) *Batch { return b.addCount("weof", n, false) }
+
+// PositionEOD adds position to end of valid data to the batch.
+func (b *Batch) PositionEOD() *Batch { return b.add(false, "eod") }
+
+// Rewind adds rewind to the batch.
+func (b *Batch) Rewind() *Batch { return b.add(false, "rewind") }
+
+// Status adds status to the batch. Its output is returned by Run.
+func (b *Batch) Status() *Batch { return b.add(true, "status") }
+
+// Tell adds tell to the batch. Its output is returned by Run.
+func (b *Batch) Tell() *Batch { return b.add(true, "tell") }
+
+// Run runs the batch operations in order, stopping at the first error.
+// It returns the output of each Status and Tell operation that ran, in
+// order.
+func (b *Batch) Run() ([]string, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	b.d.mu.Lock()
+	defer b.d.mu.Unlock()
+	var outputs []string
+	for _, op := range b.ops {
+		run := b.d.run
+		if op.output {
+			run = b.d.output
+		}
+		result, err := run(op.args[0], op.args[1:]...)
+		if err != nil {
+			return outputs, errors.Wrap(err, op.args[0])
+		}
+		if op.output {
+			outputs = append(outputs, string(result))
+		}
+	}
+	return outputs, nil
+}
@@ -0,0 +1,65 @@
+package mt
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Capabilities describes features of a drive and its loaded tape as far
+// as status and stshowopt report them. Features that cannot be determined
+// are left false.
+type Capabilities struct {
+	// CanPartition the can-partitions driver option is set or the tape
+	// is positioned on a partition other than 0
+	CanPartition bool
+	// CanCompress the density is an LTO generation, all of which support
+	// compression; other drives may support it without reporting it
+	CanCompress bool
+	// HasSetMarks the density is a DDS generation, which support set
+	// marks, or status reports the tape at a set mark
+	HasSetMarks bool
+	// Options are the driver options reported by stshowopt, nil if the
+	// dialect does not support stshowopt
+	Options []string
+}
+
+// parseOptions returns the option names listed in stshowopt output,
+// e.g. "The options set: buffer-writes async-writes can-bsr".
+func parseOptions(out string) []string {
+	if i := strings.Index(out, ":"); i >= 0 {
+		out = out[i+1:]
+	}
+	return strings.Fields(out)
+}
+
+func hasOption(opts []string, opt string) bool {
+	for _, o := range opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// Capabilities probes the drive with status and, where the dialect
+// supports it, stshowopt.
+func (d *Drive) Capabilities() (Capabilities, error) {
+	var c Capabilities
+	if d.Supports("stshowopt") {
+		out, err := d.StShowOptions()
+		if err != nil {
+			return c, errors.Wrap(err, "capabilities")
+		}
+		c.Options = parseOptions(out)
+	}
+	info, err := d.StatusInfo()
+	if err != nil {
+		return c, errors.Wrap(err, "capabilities")
+	}
+	name := Densities[info.DensityCode]
+	c.CanPartition = hasOption(c.Options, "can-partitions") || info.Partition > 0
+	c.CanCompress = strings.HasPrefix(name, "LTO")
+	c.HasSetMarks = strings.HasPrefix(name, "DDS") || info.Bits.SM
+	return c, nil
+}
@@ -0,0 +1,98 @@
+package mt
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorClass is a broad category of error returned by a Drive.
+type ErrorClass int
+
+// Error classes returned by Classify.
+const (
+	// ClassNone is the class of a nil error
+	ClassNone ErrorClass = iota
+	// ClassUnknown errors are not recognized
+	ClassUnknown
+	// ClassTransient errors may succeed when retried
+	ClassTransient
+	// ClassMedia errors are caused by the tape or its absence
+	ClassMedia
+	// ClassHardware errors are reported by the drive itself
+	ClassHardware
+	// ClassUsage errors are caused by the request, such as an operation
+	// the drive or dialect does not support
+	ClassUsage
+)
+
+var classNames = map[ErrorClass]string{
+	ClassNone:      "none",
+	ClassUnknown:   "unknown",
+	ClassTransient: "transient",
+	ClassMedia:     "media",
+	ClassHardware:  "hardware",
+	ClassUsage:     "usage",
+}
+
+func (c ErrorClass) String() string {
+	if name, ok := classNames[c]; ok {
+		return name
+	}
+	return "ErrorClass(" + strconv.Itoa(int(c)) + ")"
+}
+
+// errorClasses maps sentinel errors to their class.
+var errorClasses = []struct {
+	err   error
+	class ErrorClass
+}{
+	{ErrDeviceBusy, ClassTransient},
+	{ErrDriveNotReady, ClassTransient},
+	{context.DeadlineExceeded, ClassTransient},
+	{ErrNoMedium, ClassMedia},
+	{ErrWriteProtected, ClassMedia},
+	{ErrUnsupportedOperation, ClassUsage},
+	{ErrPermissionDenied, ClassUsage},
+	{ErrVerifyFailed, ClassHardware},
+	{ErrMechanicalFailure, ClassHardware},
+}
+
+// stderrClasses maps mt stderr messages without a sentinel error to their
+// class. Matching is case insensitive.
+var stderrClasses = []struct {
+	class    ErrorClass
+	patterns []string
+}{
+	{ClassMedia, []string{"medium error", "blank check"}},
+	{ClassHardware, []string{"input/output error", "hardware error"}},
+	{ClassUsage, []string{"invalid argument", "unrecognized", "usage:"}},
+}
+
+// Classify returns the class of err by inspecting its chain for the
+// package sentinel errors, a context deadline, and the stderr of a
+// CommandError.
+func Classify(err error) ErrorClass {
+	if err == nil {
+		return ClassNone
+	}
+	for _, ec := range errorClasses {
+		if errors.Is(err, ec.err) {
+			return ec.class
+		}
+	}
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		s := strings.ToLower(cmdErr.Stderr)
+		for _, sc := range stderrClasses {
+			for _, p := range sc.patterns {
+				if strings.Contains(s, p) {
+					return sc.class
+				}
+			}
+		}
+	}
+	return ClassUnknown
+}
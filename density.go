@@ -0,0 +1,125 @@
+package mt
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Densities maps density codes to human readable names. It is consulted
+// by Density, DensityName and DensityCodeByName and may be extended with
+// codes for other drives.
+var Densities = map[int64]string{
+	0x00: "default",
+	0x13: "DDS",
+	0x24: "DDS-2",
+	0x25: "DDS-3",
+	0x26: "DDS-4",
+	0x47: "DAT-72",
+	0x40: "LTO-1",
+	0x42: "LTO-2",
+	0x44: "LTO-3",
+	0x46: "LTO-4",
+	0x58: "LTO-5",
+	0x5a: "LTO-6",
+	0x5c: "LTO-7",
+	0x5d: "LTO-M8",
+	0x5e: "LTO-8",
+	0x60: "LTO-9",
+}
+
+// Density returns the density code reported in status and its name from
+// Densities. When the code is not in Densities the name is the code in
+// hex, e.g. "0x4b".
+func (d *Drive) Density() (int64, string, error) {
+	info, err := d.StatusInfo()
+	if err != nil {
+		return 0, "", errors.Wrap(err, "density")
+	}
+	if info.DensityCode < 0 {
+		return 0, "", errors.New("density: density code not reported in status")
+	}
+	return info.DensityCode, DensityName(info.DensityCode), nil
+}
+
+// DensityName returns the name for a density code from Densities, or the
+// code in hex if it is not known.
+func DensityName(code int64) string {
+	if name, ok := Densities[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("%#x", code)
+}
+
+// DensityCodeByName returns the density code for a name in Densities.
+// Names are matched case insensitively.
+func DensityCodeByName(name string) (int64, bool) {
+	for code, n := range Densities {
+		if strings.EqualFold(n, name) {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// densityNames returns the sorted names in Densities.
+func densityNames() []string {
+	names := make([]string, 0, len(Densities))
+	for _, n := range Densities {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetDensityByName (SCSI tapes) set the tape density to the code for name
+// in Densities.
+func (d *Drive) SetDensityByName(name string) error {
+	code, ok := DensityCodeByName(name)
+	if !ok {
+		return errors.Errorf("setdensity: unknown density %q, valid names: %s",
+			name, strings.Join(densityNames(), ", "))
+	}
+	return d.SetDensity(code)
+}
+
+// Density is a density code and its name.
+type Density struct {
+	// Code is the density code
+	Code int64
+	// Name is the description of the code
+	Name string
+}
+
+var densityLineRe = regexp.MustCompile(`(?m)^\s*(0x[0-9a-fA-F]+)\s+(.+?)\s*$`)
+
+// SupportedDensities returns the density codes listed by the densities
+// operation, with the description mt prints for each. mt-st builds the
+// list into mt rather than asking the drive, so a code being listed does
+// not guarantee the drive can write it. ErrUnsupportedOperation is
+// returned if the dialect has no densities operation.
+func (d *Drive) SupportedDensities() ([]Density, error) {
+	if !d.Supports("densities") {
+		return nil, errors.Wrapf(ErrUnsupportedOperation, "densities: %s mt", d.dialect().Name)
+	}
+	out, err := d.ListDensities()
+	if err != nil {
+		return nil, err
+	}
+	var list []Density
+	for _, m := range densityLineRe.FindAllStringSubmatch(out, -1) {
+		code, err := strconv.ParseInt(m[1], 0, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "densities")
+		}
+		list = append(list, Density{Code: code, Name: m[2]})
+	}
+	if list == nil {
+		return nil, errors.Errorf("densities: unexpected output %q", strings.TrimSpace(out))
+	}
+	return list, nil
+}
@@ -0,0 +1,48 @@
+package mt
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// deviceLocks holds the locks shared by Drives created with
+// NewDriveShared or NewDriveResolved, keyed by resolved device path.
+var deviceLocks = struct {
+	mu sync.Mutex
+	m  map[string]*sync.Mutex
+}{m: make(map[string]*sync.Mutex)}
+
+// deviceLock returns the shared lock for the resolved device path key.
+func deviceLock(key string) *sync.Mutex {
+	deviceLocks.mu.Lock()
+	defer deviceLocks.mu.Unlock()
+	l, ok := deviceLocks.m[key]
+	if !ok {
+		l = &sync.Mutex{}
+		deviceLocks.m[key] = l
+	}
+	return l
+}
+
+// NewDriveShared returns a Drive like NewDrive whose mt commands are
+// serialized with those of every other shared Drive for the same device,
+// in this process, as well as with its own. Devices are matched by path
+// with symlinks resolved, as for NewDriveResolved, so e.g. /dev/tape and
+// /dev/nst0 share a lock, and ResolvedDevice is set. A path that cannot
+// be resolved is used as is.
+//
+// The shared lock is held for each mt process only. Sequences that hold
+// the Drive lock across several commands, such as a Batch, are atomic
+// with respect to their own Drive but can still interleave with commands
+// from another shared Drive between steps. Drives created by
+// NewDriveResolved take the same lock and those created any other way do
+// not. The rewinding and non-rewinding nodes of a drive, e.g. /dev/st0
+// and /dev/nst0, are different paths and do not share one.
+func NewDriveShared(device string) *Drive {
+	d := NewDrive(device)
+	if resolved, err := resolveDevice(device); err == nil {
+		d.ResolvedDevice = resolved
+	}
+	d.devLock = deviceLock(filepath.Clean(d.devicePath()))
+	return d
+}
@@ -0,0 +1,286 @@
+package mt
+
+import (
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Dialect describes an mt implementation: the keyword it uses for each
+// operation and how to parse its status output.
+type Dialect struct {
+	// Name identifies the dialect
+	Name string
+	// Ops maps the operation names used by this package, which are the
+	// mt-st keywords, to the keyword of this mt. Operations missing from
+	// Ops are not supported. A nil Ops passes all names unchanged.
+	Ops map[string]string
+	// ParseStatus parses the output of the status operation
+	ParseStatus func(out string) (*StatusInfo, error)
+	// VersionArgs are the arguments printing the mt version, nil if the
+	// dialect has no version flag
+	VersionArgs []string
+	// ShortErase and LongErase are the erase arguments selecting a short
+	// or long erase, empty if the dialect does not take one
+	ShortErase, LongErase string
+}
+
+// keyword returns the keyword for op in the dialect.
+func (dl *Dialect) keyword(op string) (string, error) {
+	if dl.Ops == nil {
+		return op, nil
+	}
+	kw, ok := dl.Ops[op]
+	if !ok {
+		return "", errors.Wrapf(ErrUnsupportedOperation, "%s: %s mt", op, dl.Name)
+	}
+	return kw, nil
+}
+
+// Supports reports whether op, an mt-st operation keyword, is supported
+// by the dialect.
+func (dl *Dialect) Supports(op string) bool {
+	_, err := dl.keyword(op)
+	return err == nil
+}
+
+// identityOps returns an Ops map passing each of ops unchanged.
+func identityOps(ops ...string) map[string]string {
+	m := make(map[string]string, len(ops))
+	for _, op := range ops {
+		m[op] = op
+	}
+	return m
+}
+
+// LinuxDialect is mt-st as found in most Linux distros.
+var LinuxDialect = &Dialect{
+	Name: "linux",
+	// every operation in the mt-st man page, including its aliases
+	Ops: identityOps(
+		"asf", "bsf", "bsfm", "bsr", "bss", "compression", "datcompression",
+		"defblksize", "defcompression", "defdensity", "defdrvbuffer",
+		"densities", "drvbuffer", "eject", "eod", "eof", "erase", "fsf", "fsfm",
+		"fsr", "fss", "load", "lock", "mkpartition", "offline", "options",
+		"partseek", "retension", "rewind", "rewoffl", "seek", "seod", "setblk",
+		"setdensity", "setpartition", "status", "stclearoptions",
+		"stlongtimeout", "stoptions", "stsetcln", "stsetoptions", "stshowopt",
+		"sttimeout", "stwrthreshold", "tell", "unlock", "weof", "wset",
+	),
+	ParseStatus: ParseStatus,
+	VersionArgs: []string{"--version"},
+	// st sets the long bit for any non-zero erase count
+	ShortErase: "0",
+	LongErase:  "1",
+}
+
+// FreeBSDDialect is the FreeBSD base system mt.
+var FreeBSDDialect = &Dialect{
+	Name: "freebsd",
+	Ops: map[string]string{
+		"fsf":         "fsf",
+		"bsf":         "bsf",
+		"bsfm":        "nbsf",
+		"fsr":         "fsr",
+		"bsr":         "bsr",
+		"eod":         "eod",
+		"rewind":      "rewind",
+		"eject":       "offline",
+		"offline":     "offline",
+		"retension":   "retension",
+		"weof":        "weof",
+		"wset":        "smk",
+		"erase":       "erase",
+		"status":      "status",
+		"seek":        "setspos",
+		"tell":        "rdspos",
+		"load":        "load",
+		"setblk":      "blocksize",
+		"setdensity":  "density",
+		"compression": "comp",
+		"rblim":       "rblim",
+		"errstat":     "errstat",
+		"weofi":       "weofi",
+	},
+	ParseStatus: ParseFreeBSDStatus,
+	ShortErase:  "0",
+	LongErase:   "1",
+}
+
+// GNUDialect is the mt shipped with GNU cpio. It supports only the basic
+// positioning operations and none of the mt-st SCSI extensions.
+var GNUDialect = &Dialect{
+	Name: "gnu",
+	Ops: map[string]string{
+		"fsf":       "fsf",
+		"bsf":       "bsf",
+		"fsr":       "fsr",
+		"bsr":       "bsr",
+		"eod":       "eom",
+		"rewind":    "rewind",
+		"eject":     "eject",
+		"offline":   "offline",
+		"retension": "retension",
+		"weof":      "weof",
+		"erase":     "erase",
+		"status":    "status",
+	},
+	ParseStatus: ParseStatus,
+	VersionArgs: []string{"--version"},
+}
+
+// DefaultDialect is the dialect used by a Drive with a nil Dialect. It is
+// FreeBSDDialect on FreeBSD and LinuxDialect elsewhere.
+var DefaultDialect = defaultDialect()
+
+func defaultDialect() *Dialect {
+	if runtime.GOOS == "freebsd" {
+		return FreeBSDDialect
+	}
+	return LinuxDialect
+}
+
+// Supports reports whether op, an mt-st operation keyword such as
+// "setpartition", is supported by the Drive's dialect and allowed by
+// AllowedOps. Operations reported as supported may still fail with
+// ErrUnsupportedOperation if the drive itself does not support them.
+func (d *Drive) Supports(op string) bool {
+	_, err := d.keyword(op)
+	return err == nil
+}
+
+var versionRe = regexp.MustCompile(`\d+(?:\.\d+)+`)
+
+// Version returns the version of the Drive's mt command, e.g. "1.1" for
+// mt-st v. 1.1. If no version number is found the first line of output is
+// returned. ErrUnsupportedOperation is returned if the dialect has no
+// version flag or mt rejects it.
+func (d *Drive) Version() (string, error) {
+	dl := d.dialect()
+	if dl.VersionArgs == nil {
+		return "", errors.Wrapf(ErrUnsupportedOperation, "version: %s mt", dl.Name)
+	}
+	out, err := d.execArgs(dl.VersionArgs)
+	if err == nil && d.DryRun {
+		err = ErrDryRun
+	}
+	if err != nil {
+		var cmdErr *CommandError
+		if errors.As(err, &cmdErr) {
+			err = errors.Wrap(ErrUnsupportedOperation, cmdErr.Error())
+		}
+		return "", errors.Wrap(err, "version")
+	}
+	if v := versionRe.FindString(string(out)); v != "" {
+		return v, nil
+	}
+	line := strings.SplitN(strings.TrimSpace(string(out)), "\n", 2)[0]
+	return line, nil
+}
+
+var (
+	minBlockRe = regexp.MustCompile(`(?i)min(?:imum)? blocksize:?\s+(\d+)`)
+	maxBlockRe = regexp.MustCompile(`(?i)max(?:imum)? blocksize:?\s+(\d+)`)
+)
+
+// BlockLimits returns the minimum and maximum block size supported by the
+// drive in variable block mode, read with the rblim operation where the
+// dialect has one. mt-st has no such operation, so ErrUnsupportedOperation
+// is returned on Linux.
+func (d *Drive) BlockLimits() (min, max int64, err error) {
+	if !d.Supports("rblim") {
+		return 0, 0, errors.Wrapf(ErrUnsupportedOperation, "block limits: %s mt", d.dialect().Name)
+	}
+	d.mu.Lock()
+	out, err := d.output("rblim")
+	d.mu.Unlock()
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "block limits")
+	}
+	if min, err = findInt(minBlockRe, string(out), 10); err != nil {
+		return 0, 0, errors.Wrap(err, "block limits")
+	}
+	if max, err = findInt(maxBlockRe, string(out), 10); err != nil {
+		return 0, 0, errors.Wrap(err, "block limits")
+	}
+	if min < 0 || max < 0 {
+		return 0, 0, errors.Errorf("block limits: unexpected output %q", strings.TrimSpace(string(out)))
+	}
+	return min, max, nil
+}
+
+func (d *Drive) dialect() *Dialect {
+	if d.Dialect == nil {
+		return DefaultDialect
+	}
+	return d.Dialect
+}
+
+var (
+	bsdFileNumberRe  = regexp.MustCompile(`(?:Reported )?File Number:\s+(-?\d+)`)
+	bsdBlockNumberRe = regexp.MustCompile(`Record Number:\s+(-?\d+)`)
+	bsdPartitionRe   = regexp.MustCompile(`Partition:\s+(-?\d+)`)
+	bsdResidualRe    = regexp.MustCompile(`Residual(?: Count)?:?\s+(-?\d+)`)
+	bsdCurrentRe     = regexp.MustCompile(`Current:\s+(0x[0-9a-fA-F]+)\S*\s+(variable|\d+)(?:\s+bytes)?(?:\s+\d+\s+(\w+))?`)
+	bsdFlagsRe       = regexp.MustCompile(`Flags:[ \t]*(.*)`)
+)
+
+// ParseFreeBSDStatus parses the output of FreeBSD mt status.
+func ParseFreeBSDStatus(out string) (*StatusInfo, error) {
+	info := &StatusInfo{Partitions: -1, BlockSize: -1, DensityCode: -1, SoftErrors: -1}
+	var err error
+	if info.FileNumber, err = findInt(bsdFileNumberRe, out, 10); err != nil {
+		return nil, errors.Wrap(err, "parse file number")
+	}
+	if info.BlockNumber, err = findInt(bsdBlockNumberRe, out, 10); err != nil {
+		return nil, errors.Wrap(err, "parse block number")
+	}
+	if info.Partition, err = findInt(bsdPartitionRe, out, 10); err != nil {
+		return nil, errors.Wrap(err, "parse partition")
+	}
+	if info.Residual, err = findIntPtr(bsdResidualRe, out); err != nil {
+		return nil, errors.Wrap(err, "parse residual")
+	}
+	var flags Flags
+	if m := bsdCurrentRe.FindStringSubmatch(out); m != nil {
+		n, err := strconv.ParseInt(m[1], 0, 64)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse density code")
+		}
+		info.DensityCode = n
+		// FreeBSD mt status fails without a loaded tape, so reporting
+		// the current mode means the drive is online
+		flags |= Flags(FlagOnline)
+		if m[2] == "variable" {
+			info.BlockSize = 0
+		} else {
+			n, err := strconv.ParseInt(m[2], 10, 64)
+			if err != nil {
+				return nil, errors.Wrap(err, "parse block size")
+			}
+			info.BlockSize = n
+		}
+		if m[3] != "" {
+			info.Compression = parseCompression(m[3])
+		}
+	}
+	if m := bsdFlagsRe.FindStringSubmatch(out); m != nil {
+		for _, f := range strings.Fields(m[1]) {
+			switch f {
+			case "BOT":
+				flags |= Flags(FlagBOT)
+			case "EOT", "EOM":
+				flags |= Flags(FlagEOT)
+			case "EOD":
+				flags |= Flags(FlagEOD)
+			case "EOF":
+				flags |= Flags(FlagEOF)
+			}
+		}
+	}
+	info.Bits = newStatusBits(0, flags)
+	return info, nil
+}
@@ -0,0 +1,82 @@
+package mt
+
+import (
+	"strings"
+	"time"
+)
+
+// Option configures a Drive created with NewDriveOpts.
+type Option func(*Drive)
+
+// Logger is the logging interface used by WithLogger, satisfied by
+// *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// NewDriveOpts returns a Drive for a given device path configured by
+// opts, applied in order. Without options it is the same as NewDrive.
+func NewDriveOpts(device string, opts ...Option) *Drive {
+	d := &Drive{Device: device, Command: defaultCommand()}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// WithCommand sets the mt command. An empty cmd keeps the default, as for
+// NewDriveCmd.
+func WithCommand(cmd string) Option {
+	return func(d *Drive) {
+		if cmd != "" {
+			d.Command = cmd
+		}
+	}
+}
+
+// WithDialect sets the Drive's Dialect.
+func WithDialect(dialect *Dialect) Option {
+	return func(d *Drive) { d.Dialect = dialect }
+}
+
+// WithTimeout sets the Drive's Timeout for each mt process.
+func WithTimeout(timeout time.Duration) Option {
+	return func(d *Drive) { d.Timeout = timeout }
+}
+
+// WithRunner sets the Runner used to run mt.
+func WithRunner(r Runner) Option {
+	return func(d *Drive) { d.Runner = r }
+}
+
+// WithRetries sets MaxRetries and RetryBackoff for transient errors.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(d *Drive) {
+		d.MaxRetries = n
+		d.RetryBackoff = backoff
+	}
+}
+
+// WithDryRun sets DryRun, so mt is never run.
+func WithDryRun() Option {
+	return func(d *Drive) { d.DryRun = true }
+}
+
+// WithLogger logs every mt command with its duration and error to l. It
+// is installed as OnCommand, calling any OnCommand set by an earlier
+// option first.
+func WithLogger(l Logger) Option {
+	return func(d *Drive) {
+		prev := d.OnCommand
+		d.OnCommand = func(args []string, dur time.Duration, err error) {
+			if prev != nil {
+				prev(args, dur, err)
+			}
+			if err != nil {
+				l.Printf("mt %s: %v: %v", strings.Join(args, " "), dur, err)
+				return
+			}
+			l.Printf("mt %s: %v", strings.Join(args, " "), dur)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package mt
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// DriveSet holds multiple Drives keyed by device path and runs operations
+// on all of them concurrently. Each Drive still serializes its own
+// operations.
+type DriveSet struct {
+	mu     sync.Mutex
+	drives map[string]*Drive
+}
+
+// NewDriveSet returns a DriveSet holding drives
+func NewDriveSet(drives ...*Drive) *DriveSet {
+	s := &DriveSet{drives: make(map[string]*Drive)}
+	for _, d := range drives {
+		s.drives[d.Device] = d
+	}
+	return s
+}
+
+// Add adds d to the set, replacing any Drive with the same device path.
+func (s *DriveSet) Add(d *Drive) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drives[d.Device] = d
+}
+
+// Remove removes the Drive for device from the set.
+func (s *DriveSet) Remove(device string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.drives, device)
+}
+
+// Get returns the Drive for device, or nil if it is not in the set.
+func (s *DriveSet) Get(device string) *Drive {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.drives[device]
+}
+
+// Devices returns the sorted device paths in the set.
+func (s *DriveSet) Devices() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	devices := make([]string, 0, len(s.drives))
+	for dev := range s.drives {
+		devices = append(devices, dev)
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+// Do calls fn concurrently for each Drive in the set and waits for all
+// of them. The result has an entry for every device, nil on success.
+// Drives not yet started when ctx is done get ctx.Err(). fn is passed
+// the Drive's WithContext(ctx), so mt commands already running are
+// stopped when ctx is done; being a clone, it does not share the lock of
+// the Drive in the set.
+func (s *DriveSet) Do(ctx context.Context, fn func(d *Drive) error) map[string]error {
+	s.mu.Lock()
+	drives := make([]*Drive, 0, len(s.drives))
+	for _, d := range s.drives {
+		drives = append(drives, d)
+	}
+	s.mu.Unlock()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make(map[string]error, len(drives))
+	)
+	for _, d := range drives {
+		wg.Add(1)
+		go func(d *Drive) {
+			defer wg.Done()
+			err := ctx.Err()
+			if err == nil {
+				err = fn(d.WithContext(ctx))
+			}
+			mu.Lock()
+			results[d.Device] = err
+			mu.Unlock()
+		}(d)
+	}
+	wg.Wait()
+	return results
+}
+
+// RewindAll rewinds every Drive in the set.
+func (s *DriveSet) RewindAll(ctx context.Context) map[string]error {
+	return s.Do(ctx, (*Drive).Rewind)
+}
+
+// EjectAll ejects every Drive in the set.
+func (s *DriveSet) EjectAll(ctx context.Context) map[string]error {
+	return s.Do(ctx, (*Drive).Eject)
+}
+
+// LoadAll loads every Drive in the set.
+func (s *DriveSet) LoadAll(ctx context.Context) map[string]error {
+	return s.Do(ctx, (*Drive).Load)
+}
@@ -0,0 +1,26 @@
+package mt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestDriveSetCancel(t *testing.T) {
+	cmd := fakeMT(t, "exec sleep 10\n")
+	s := NewDriveSet(NewDriveCmd("/dev/nst0", cmd), NewDriveCmd("/dev/nst1", cmd))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	results := s.RewindAll(ctx)
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("RewindAll returned %v after cancel", elapsed)
+	}
+	for _, dev := range []string{"/dev/nst0", "/dev/nst1"} {
+		if err := results[dev]; !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: %v, want context canceled", dev, err)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package mt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrWriteProtected is returned when an operation that writes to the
+// tape fails because the loaded media is write protected.
+var ErrWriteProtected = errors.New("media is write protected")
+
+// ErrNoMedium is returned when mt fails because no tape is loaded.
+var ErrNoMedium = errors.New("no medium")
+
+// ErrDeviceBusy is returned when mt fails because the device is busy, for
+// example while the drive is still repositioning. It is a transient error
+// retried according to Drive.MaxRetries.
+var ErrDeviceBusy = errors.New("device busy")
+
+// ErrDriveNotReady is returned when mt fails because the drive reports it
+// is not ready with a tape loaded, such as "not ready, cause not
+// reportable" or "in process of becoming ready" from LTO drives still
+// loading or recovering. Unlike ErrNoMedium it usually clears by itself,
+// so it is a transient error retried according to Drive.MaxRetries.
+var ErrDriveNotReady = errors.New("drive not ready")
+
+// ErrUnsupportedOperation is returned without running mt when an
+// operation is not supported by the Drive's dialect, and when mt reports
+// the device does not support the operation.
+var ErrUnsupportedOperation = errors.New("operation not supported")
+
+// ErrPermissionDenied is returned when mt fails because the user may not
+// open the device or perform the operation, typically when not running as
+// root or a member of the tape group.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ErrMechanicalFailure is returned by Eject when the drive fails to
+// unload the cartridge, for example because it is stuck or medium removal
+// is prevented. Unlike ErrDeviceBusy it is not retried and usually needs
+// manual intervention.
+var ErrMechanicalFailure = errors.New("mechanical failure")
+
+// ErrOutputTooLarge is returned when mt writes more than Drive.MaxOutput
+// bytes to stdout or stderr.
+var ErrOutputTooLarge = errors.New("mt output too large")
+
+// ErrDryRun is returned by a Drive with DryRun set from every method that
+// reads mt output, such as Status, Tell and those parsing them, since mt
+// is not run and there is nothing to read.
+var ErrDryRun = errors.New("dry run")
+
+// ErrVerifyFailed is returned by the Verified methods when the operation
+// ran without error but status shows it did not take effect.
+var ErrVerifyFailed = errors.New("verify failed")
+
+// ErrFileMark is returned by the tape Reader when it reaches a filemark,
+// the end of the current file. Reading again continues with the next file.
+var ErrFileMark = errors.New("filemark")
+
+// CommandError is returned when the mt process runs but exits with a
+// non-zero status.
+type CommandError struct {
+	// ExitCode is the exit status of the mt process
+	ExitCode int
+	// Stderr is the output mt wrote to stderr, without the trailing newline
+	Stderr string
+	// Args is the argument list passed to mt
+	Args []string
+	// Device is the device named in the mt error message, e.g. /dev/nst0
+	// from "mt: /dev/nst0: Input/output error", empty if the message does
+	// not have that form
+	Device string
+	// Reason is the rest of that message, e.g. "Input/output error"
+	Reason string
+	// err is the underlying *exec.ExitError
+	err error
+}
+
+func (e *CommandError) Error() string {
+	msg := e.Stderr
+	if e.Reason != "" {
+		msg = e.Device + ": " + e.Reason
+	}
+	if e.err == nil {
+		return fmt.Sprintf("%s: mt wait command: exit status %d", msg, e.ExitCode)
+	}
+	return msg + ": mt wait command: " + e.err.Error()
+}
+
+// stderrLineRe matches an mt error message line, "/dev/nst0: reason",
+// optionally prefixed by the program name as in "mt: /dev/nst0: reason"
+// or "/bin/mt: /dev/nst0: reason".
+var stderrLineRe = regexp.MustCompile(`^\s*(?:\S*mt\s*:\s*)?(/[^:\s]+)\s*:\s*(.+?)\s*$`)
+
+// parseStderr sets Device and Reason from the last line of Stderr naming
+// a device, if they are not already set.
+func (e *CommandError) parseStderr() {
+	if e.Reason != "" {
+		return
+	}
+	lines := strings.Split(e.Stderr, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if m := stderrLineRe.FindStringSubmatch(lines[i]); m != nil {
+			e.Device, e.Reason = m[1], m[2]
+			return
+		}
+	}
+}
+
+// Unwrap returns the underlying *exec.ExitError.
+func (e *CommandError) Unwrap() error { return e.err }
+
+// stderrSignatures maps known mt stderr messages to the sentinel error
+// they indicate. Matching is case insensitive and the first sentinel with
+// a matching pattern wins, so e.g. "not ready, medium not present" is
+// ErrNoMedium rather than ErrDriveNotReady.
+var stderrSignatures = []struct {
+	sentinel error
+	patterns []string
+}{
+	{ErrWriteProtected, []string{
+		"read-only file system",
+		"write protected",
+		"write-protected",
+		"data protect",
+	}},
+	{ErrNoMedium, []string{
+		"no medium",
+		"medium not present",
+	}},
+	{ErrDriveNotReady, []string{
+		"cause not reportable",
+		"becoming ready",
+		"not ready",
+	}},
+	{ErrDeviceBusy, []string{
+		"device or resource busy",
+	}},
+	{ErrUnsupportedOperation, []string{
+		"operation not supported",
+		"inappropriate ioctl for device",
+	}},
+	{ErrPermissionDenied, []string{
+		"permission denied",
+		"operation not permitted",
+	}},
+}
+
+// ejectSignatures are the stderr messages of a failed eject meaning the
+// cartridge could not be unloaded. A plain I/O error is included since
+// the st driver reports a failed unload as EIO.
+var ejectSignatures = []string{
+	"input/output error",
+	"medium removal prevented",
+	"load or eject failed",
+	"mechanical",
+}
+
+// matchEject returns the error of a failed eject annotated with
+// ErrMechanicalFailure when its stderr shows the cartridge could not be
+// unloaded. Errors already matched, such as ErrDeviceBusy, are returned
+// unchanged.
+func matchEject(err error) error {
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		return err
+	}
+	var se *stderrError
+	if errors.As(err, &se) {
+		return err
+	}
+	s := strings.ToLower(cmdErr.Stderr)
+	for _, p := range ejectSignatures {
+		if strings.Contains(s, p) {
+			return &stderrError{err: err, sentinel: ErrMechanicalFailure}
+		}
+	}
+	return err
+}
+
+// stderrError annotates a failed mt command with the sentinel error
+// recognized from its stderr, so that errors.Is matches the sentinel
+// through any further wrapping.
+type stderrError struct {
+	err      error
+	sentinel error
+}
+
+func (e *stderrError) Error() string { return e.err.Error() }
+
+// Cause returns the underlying command error.
+func (e *stderrError) Cause() error { return e.err }
+
+// Unwrap returns the underlying command error.
+func (e *stderrError) Unwrap() error { return e.err }
+
+// Is reports whether target is the recognized sentinel.
+func (e *stderrError) Is(target error) bool { return target == e.sentinel }
+
+// matchStderr returns err annotated with the first sentinel whose
+// signature appears in stderr, or err unchanged if none match.
+func matchStderr(err error, stderr string) error {
+	s := strings.ToLower(stderr)
+	for _, sig := range stderrSignatures {
+		for _, p := range sig.patterns {
+			if strings.Contains(s, p) {
+				return &stderrError{err: err, sentinel: sig.sentinel}
+			}
+		}
+	}
+	return err
+}
+
+// transientErrors are the errors that may succeed when retried.
+var transientErrors = []error{ErrDeviceBusy, ErrDriveNotReady}
+
+func isTransient(err error) bool {
+	for _, t := range transientErrors {
+		if errors.Is(err, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// destructiveOps are operation keywords, in any dialect, that write to the
+// tape and so must never be retried.
+var destructiveOps = map[string]bool{
+	"erase":       true,
+	"weof":        true,
+	"weofi":       true,
+	"eof":         true,
+	"wset":        true,
+	"smk":         true,
+	"mkpartition": true,
+}
+
+// retryable reports whether an mt argument list contains no destructive
+// operations.
+func retryable(args []string) bool {
+	for _, a := range args {
+		if destructiveOps[a] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,73 @@
+package mt
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// failRunner returns a Runner failing every command with stderr.
+func failRunner(stderr string) Runner {
+	return RunnerFunc(func(name string, args []string) ([]byte, error) {
+		return nil, &CommandError{ExitCode: 2, Stderr: stderr, Args: args}
+	})
+}
+
+func TestMatchStderr(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   error
+	}{
+		{"mt: /dev/nst0: Read-only file system", ErrWriteProtected},
+		{"/dev/nst0: Read-only file system", ErrWriteProtected},
+		{"mt: /dev/nsa0: Write protected", ErrWriteProtected},
+		{"Sense Key : Data Protect [current]", ErrWriteProtected},
+		{"mt: /dev/nst0: No medium found", ErrNoMedium},
+		{"mt: /dev/nst0: Device or resource busy", ErrDeviceBusy},
+		{"mt: /dev/nst0: Inappropriate ioctl for device", ErrUnsupportedOperation},
+		{"mt: /dev/nst0: Permission denied", ErrPermissionDenied},
+		{"mt: /dev/nst0: Input/output error", nil},
+		{"st 2:0:0:0: [st0] Sense Key : Not Ready [current]\n" +
+			"st 2:0:0:0: [st0] Add. Sense: Logical unit not ready, cause not reportable\n" +
+			"mt: /dev/nst0: Input/output error", ErrDriveNotReady},
+		{"mt: /dev/nst0: Logical unit not ready, cause not reportable", ErrDriveNotReady},
+		{"st 2:0:0:0: [st0] Add. Sense: Logical unit is in process of becoming ready\n" +
+			"mt: /dev/nst0: Input/output error", ErrDriveNotReady},
+		{"st 2:0:0:0: [st0] Sense Key : Not Ready [current]\n" +
+			"st 2:0:0:0: [st0] Add. Sense: Medium not present\n" +
+			"mt: /dev/nst0: No medium found", ErrNoMedium},
+		{"mt: /dev/nst0: not ready, medium not present", ErrNoMedium},
+	}
+	sentinels := []error{ErrWriteProtected, ErrNoMedium, ErrDeviceBusy,
+		ErrUnsupportedOperation, ErrPermissionDenied, ErrDriveNotReady}
+	for _, tt := range tests {
+		cmdErr := &CommandError{ExitCode: 2, Stderr: tt.stderr}
+		err := matchStderr(cmdErr, tt.stderr)
+		for _, s := range sentinels {
+			if got := errors.Is(err, s); got != (s == tt.want) {
+				t.Errorf("%q: errors.Is(%v) = %t", tt.stderr, s, got)
+			}
+		}
+		var ce *CommandError
+		if !errors.As(err, &ce) || ce != cmdErr {
+			t.Errorf("%q: CommandError lost", tt.stderr)
+		}
+	}
+}
+
+func TestWriteProtectedThroughWrap(t *testing.T) {
+	d := NewDrive("/dev/nst0")
+	d.Runner = failRunner("mt: /dev/nst0: Read-only file system")
+	for name, op := range map[string]func() error{
+		"erase": d.Erase,
+		"weof":  func() error { return d.WriteEOFMarks(1) },
+	} {
+		err := errors.Wrap(op(), "outer")
+		if !errors.Is(err, ErrWriteProtected) {
+			t.Errorf("%s: %v is not ErrWriteProtected", name, err)
+		}
+		if errors.Is(err, ErrNoMedium) {
+			t.Errorf("%s: %v is ErrNoMedium", name, err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package mt
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// StatusBits holds the general status bits reported by mt status.
+type StatusBits struct {
+	// Raw is the hex value printed after "General status bits on",
+	// zero when not printed
+	Raw uint32
+	// Flags is the set of bits reported by either the hex value or the
+	// symbolic names; the booleans below mirror it
+	Flags Flags
+	// EOF the tape is positioned just after a filemark
+	EOF bool
+	// BOT the tape is positioned at the beginning of tape
+	BOT bool
+	// EOT the tape is positioned at the end of tape
+	EOT bool
+	// SM the tape is positioned at a setmark
+	SM bool
+	// EOD the tape is positioned at the end of recorded data
+	EOD bool
+	// WrProt the tape is write protected
+	WrProt bool
+	// Online the drive is online with a tape loaded
+	Online bool
+	// DrOpen the drive door is open or no tape is loaded
+	DrOpen bool
+	// ImRepEn immediate report mode is enabled
+	ImRepEn bool
+	// CleanReq the drive is requesting cleaning
+	CleanReq bool
+}
+
+// Flag is a general status bit, with the value from linux/mtio.h.
+type Flag uint32
+
+// General status bits from linux/mtio.h
+const (
+	FlagEOF     Flag = 0x80000000
+	FlagBOT     Flag = 0x40000000
+	FlagEOT     Flag = 0x20000000
+	FlagSM      Flag = 0x10000000
+	FlagEOD     Flag = 0x08000000
+	FlagWrProt  Flag = 0x04000000
+	FlagOnline  Flag = 0x01000000
+	FlagDrOpen  Flag = 0x00040000
+	FlagImRepEn Flag = 0x00010000
+	FlagCln     Flag = 0x00008000
+)
+
+// flagNames are the mt names of each Flag, in the order mt prints them.
+var flagNames = []struct {
+	flag Flag
+	name string
+}{
+	{FlagEOF, "EOF"},
+	{FlagBOT, "BOT"},
+	{FlagEOT, "EOT"},
+	{FlagSM, "SM"},
+	{FlagEOD, "EOD"},
+	{FlagWrProt, "WR_PROT"},
+	{FlagOnline, "ONLINE"},
+	{FlagDrOpen, "DR_OPEN"},
+	{FlagImRepEn, "IM_REP_EN"},
+	{FlagCln, "CLN"},
+}
+
+// String returns the mt names of the bits in f separated by spaces.
+func (f Flag) String() string {
+	return Flags(f).String()
+}
+
+// Flags is a set of general status bits.
+type Flags uint32
+
+// Has reports whether all bits of flag are set in f, e.g.
+// f.Has(FlagOnline|FlagBOT).
+func (f Flags) Has(flag Flag) bool {
+	return Flag(f)&flag == flag
+}
+
+// String returns the mt names of the bits set in f separated by spaces,
+// e.g. "BOT ONLINE". Bits without a name are omitted.
+func (f Flags) String() string {
+	return strings.Join(f.names(), " ")
+}
+
+// names returns the mt names of the bits set in f.
+func (f Flags) names() []string {
+	names := []string{}
+	for _, fn := range flagNames {
+		if f.Has(fn.flag) {
+			names = append(names, fn.name)
+		}
+	}
+	return names
+}
+
+// newStatusBits returns the StatusBits for raw and the flags set.
+func newStatusBits(raw uint32, f Flags) StatusBits {
+	return StatusBits{
+		Raw:      raw,
+		Flags:    f,
+		EOF:      f.Has(FlagEOF),
+		BOT:      f.Has(FlagBOT),
+		EOT:      f.Has(FlagEOT),
+		SM:       f.Has(FlagSM),
+		EOD:      f.Has(FlagEOD),
+		WrProt:   f.Has(FlagWrProt),
+		Online:   f.Has(FlagOnline),
+		DrOpen:   f.Has(FlagDrOpen),
+		ImRepEn:  f.Has(FlagImRepEn),
+		CleanReq: f.Has(FlagCln),
+	}
+}
+
+var generalRe = regexp.MustCompile(`General status bits on \(([0-9a-fA-F]*)\):[ \t]*(?:\n(.*))?`)
+
+// ParseGeneralStatusBits parses the general status bits section of mt
+// status output. Both the symbolic names and the raw hex value are used
+// when present, so a bit is set if either reports it.
+func ParseGeneralStatusBits(out string) StatusBits {
+	m := generalRe.FindStringSubmatch(out)
+	if m == nil {
+		return StatusBits{}
+	}
+	var raw uint32
+	if m[1] != "" {
+		n, err := strconv.ParseUint(m[1], 16, 32)
+		if err == nil {
+			raw = uint32(n)
+		}
+	}
+	f := Flags(raw)
+	for _, name := range strings.Fields(m[2]) {
+		for _, fn := range flagNames {
+			if name == fn.name {
+				f |= Flags(fn.flag)
+			}
+		}
+	}
+	return newStatusBits(raw, f)
+}
+
+// StatusBits runs status and returns the general status bits.
+func (d *Drive) StatusBits() (StatusBits, error) {
+	info, err := d.StatusInfo()
+	if err != nil {
+		return StatusBits{}, errors.Wrap(err, "status bits")
+	}
+	return info.Bits, nil
+}
+
+// Flags runs status and returns the general status bits set.
+func (d *Drive) Flags() (Flags, error) {
+	b, err := d.StatusBits()
+	return b.Flags, err
+}
+
+// AtBOT reports whether the tape is positioned at the beginning of tape.
+func (d *Drive) AtBOT() (bool, error) {
+	b, err := d.StatusBits()
+	return b.BOT, err
+}
+
+// AtStart reports whether the tape is at the logical beginning of the
+// current partition, meaning status reports file number 0, block number 0
+// and the BOT bit, all from the same status. A drive that reports the
+// position as unknown is not at the start, even with BOT set.
+func (d *Drive) AtStart() (bool, error) {
+	info, err := d.StatusInfo()
+	if err != nil {
+		return false, errors.Wrap(err, "at start")
+	}
+	return info.FileNumber == 0 && info.BlockNumber == 0 && info.Bits.BOT, nil
+}
+
+// AtEOT reports whether the tape is positioned at the end of tape.
+func (d *Drive) AtEOT() (bool, error) {
+	b, err := d.StatusBits()
+	return b.EOT, err
+}
+
+// AtEOF reports whether the tape is positioned just after a filemark.
+func (d *Drive) AtEOF() (bool, error) {
+	b, err := d.StatusBits()
+	return b.EOF, err
+}
+
+// AtEOD reports whether the tape is positioned at the end of recorded data.
+func (d *Drive) AtEOD() (bool, error) {
+	b, err := d.StatusBits()
+	return b.EOD, err
+}
+
+// WriteProtected reports whether the loaded tape is write protected.
+func (d *Drive) WriteProtected() (bool, error) {
+	b, err := d.StatusBits()
+	return b.WrProt, err
+}
+
+// DoorOpen reports whether the drive door is open or no tape is loaded.
+func (d *Drive) DoorOpen() (bool, error) {
+	b, err := d.StatusBits()
+	return b.DrOpen, err
+}
+
+// CleaningRequired reports whether the drive is requesting cleaning,
+// the CLN general status bit.
+func (d *Drive) CleaningRequired() (bool, error) {
+	b, err := d.StatusBits()
+	return b.CleanReq, err
+}
@@ -0,0 +1,93 @@
+package mt
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Health holds drive health indicators read from Linux sysfs. Pointer
+// fields are nil when the kernel does not expose them.
+type Health struct {
+	// State is the SCSI device state, e.g. "running" or "offline"
+	State string
+	// IOErrors is the number of SCSI commands that completed with an error
+	IOErrors *int64
+	// IOTimeouts is the number of SCSI commands that timed out
+	IOTimeouts *int64
+	// ReadBytes and WriteBytes are the bytes read and written by the st
+	// driver since it attached
+	ReadBytes, WriteBytes *int64
+	// ResidCount is the number of reads and writes that transferred less
+	// than requested
+	ResidCount *int64
+	// Temperature is the drive temperature in degrees Celsius, present
+	// only if a hwmon driver is bound to the device
+	Temperature *float64
+	// CleaningRequired is the CLN general status bit, nil if status could
+	// not be read
+	CleaningRequired *bool
+}
+
+// readSysfsIntPtr returns the sysfs attribute name as an integer in base,
+// nil if it is missing.
+func readSysfsIntPtr(node, name string, base int) (*int64, error) {
+	s, err := readSysfs(node, name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.ParseInt(s, base, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// Health returns the health indicators the kernel exposes for the drive
+// in sysfs, the SCSI error counters and st statistics, along with the
+// cleaning request bit from status. The st driver itself reports no
+// temperature. ErrUnsupportedOperation is returned if the device has no
+// sysfs node.
+func (d *Drive) Health() (*Health, error) {
+	node, err := d.sysfsNode()
+	if err != nil {
+		return nil, errors.Wrap(err, "health")
+	}
+	h := &Health{}
+	if h.State, err = readSysfs(node, "device/state"); err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "health: state")
+	}
+	for _, f := range []struct {
+		dst  **int64
+		name string
+		base int
+	}{
+		// the SCSI counters are printed in hex
+		{&h.IOErrors, "device/ioerr_cnt", 0},
+		{&h.IOTimeouts, "device/iotmo_cnt", 0},
+		{&h.ReadBytes, "stats/read_byte_cnt", 10},
+		{&h.WriteBytes, "stats/write_byte_cnt", 10},
+		{&h.ResidCount, "stats/resid_cnt", 10},
+	} {
+		if *f.dst, err = readSysfsIntPtr(node, f.name, f.base); err != nil {
+			return nil, errors.Wrapf(err, "health: %s", f.name)
+		}
+	}
+	temps, _ := filepath.Glob(filepath.Join(node, "device", "hwmon", "hwmon*", "temp1_input"))
+	if len(temps) > 0 {
+		n, err := readSysfsIntPtr(filepath.Dir(temps[0]), "temp1_input", 10)
+		if err == nil && n != nil {
+			c := float64(*n) / 1000
+			h.Temperature = &c
+		}
+	}
+	if b, err := d.StatusBits(); err == nil {
+		h.CleaningRequired = &b.CleanReq
+	}
+	return h, nil
+}
@@ -0,0 +1,154 @@
+package mt
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// EachFile rewinds the tape and calls fn with the file number of each
+// file on the tape in turn. A file is only known to exist once the tape
+// has spaced over it: after the last filemark st reports the next file
+// number without EOD until a space runs into blank tape. So fn is called
+// for each file after spacing forward over it, with the tape at the start
+// of the next file. It stops without error when status reports EOD or EOT
+// or spacing forward fails at EOD, and stops with the error when fn
+// returns an error, ctx is done or status does not report the file
+// number, as with DryRun.
+func (d *Drive) EachFile(ctx context.Context, fn func(fileNum int64) error) error {
+	if err := d.Rewind(); err != nil {
+		return errors.Wrap(err, "each file")
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "each file")
+		}
+		info, err := d.StatusInfo()
+		if err != nil {
+			return errors.Wrap(err, "each file")
+		}
+		if info.Bits.EOD || info.Bits.EOT {
+			return nil
+		}
+		if info.FileNumber < 0 {
+			return errors.New("each file: file number not known")
+		}
+		if err := d.NextFile(); err != nil {
+			// spacing past the last file fails on some drives
+			// rather than stopping at EOD
+			if eod, serr := d.AtEOD(); serr == nil && eod {
+				return nil
+			}
+			return errors.Wrap(err, "each file")
+		}
+		if err := fn(info.FileNumber); err != nil {
+			return err
+		}
+	}
+}
+
+// CountFiles returns the number of files on the tape using EachFile. This
+// rewinds and spaces over every file on the tape, which can take a long
+// time on a full tape; ctx can be used to give up.
+func (d *Drive) CountFiles(ctx context.Context) (int64, error) {
+	var n int64
+	err := d.EachFile(ctx, func(int64) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "count files")
+	}
+	return n, nil
+}
+
+// EachRecord walks the records of the current file from the current
+// position, spacing forward one record at a time and calling fn with the
+// block number within the file of each record spaced over. It stops
+// without error at the filemark ending the file, at EOD or at EOT, and
+// stops with the error when fn returns an error or ctx is done.
+//
+// Spacing a record into a filemark moves the tape past it, so when status
+// shows the file number advanced the tape is spaced back before the
+// filemark; the walk never leaves the tape in the next file.
+func (d *Drive) EachRecord(ctx context.Context, fn func(block int64) error) error {
+	info, err := d.StatusInfo()
+	if err != nil {
+		return errors.Wrap(err, "each record")
+	}
+	file := info.FileNumber
+	if file < 0 {
+		return errors.New("each record: file number not known")
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "each record")
+		}
+		if info.Bits.EOD || info.Bits.EOT {
+			return nil
+		}
+		block := info.BlockNumber
+		fsrErr := d.ForwardRecords(1)
+		info, err = d.StatusInfo()
+		if err != nil {
+			return errors.Wrap(err, "each record")
+		}
+		if info.FileNumber != file {
+			return errors.Wrap(d.BackwardFiles(1), "each record")
+		}
+		if info.Bits.EOD {
+			return nil
+		}
+		if fsrErr != nil {
+			return errors.Wrap(fsrErr, "each record")
+		}
+		if err := fn(block); err != nil {
+			return err
+		}
+	}
+}
+
+// CurrentFileBlocks returns the number of blocks in the current file by
+// spacing forward record by record to the filemark with EachRecord,
+// adding the blocks before the current position, then returning to where
+// it started. This runs two mt processes for every remaining record and
+// so is very slow on large files; it is meant for recovering sizes when a
+// catalog is lost. The starting position is restored even when the walk
+// fails or ctx is done, by spacing back over the records walked, or by
+// positioning to the file and block from the starting status if that
+// does not land on it.
+func (d *Drive) CurrentFileBlocks(ctx context.Context) (int64, error) {
+	info, err := d.StatusInfo()
+	if err != nil {
+		return 0, errors.Wrap(err, "current file blocks")
+	}
+	file, block := info.FileNumber, info.BlockNumber
+	if file < 0 || block < 0 {
+		return 0, errors.New("current file blocks: position not known")
+	}
+	var n int64
+	walkErr := d.EachRecord(ctx, func(int64) error {
+		n++
+		return nil
+	})
+	if err := d.returnTo(file, block, n); err != nil {
+		return 0, errors.Wrap(err, "current file blocks: restore position")
+	}
+	if walkErr != nil {
+		return 0, errors.Wrap(walkErr, "current file blocks")
+	}
+	return block + n, nil
+}
+
+// returnTo returns the tape to block in file after spacing forward over
+// n records from there, spacing back and falling back to positioning by
+// file and block if that does not reach it.
+func (d *Drive) returnTo(file, block, n int64) error {
+	if err := d.BackwardRecords(n); err == nil {
+		info, err := d.StatusInfo()
+		if err == nil && info.FileNumber == file && info.BlockNumber == block {
+			return nil
+		}
+	}
+	return d.PositionToBlockInFile(file, block)
+}
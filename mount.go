@@ -0,0 +1,88 @@
+package mt
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultPollInterval is how often the AndWait methods poll status.
+const DefaultPollInterval = time.Second
+
+// LoadAndWait loads the tape, then waits until the drive is ready or ctx
+// is done.
+func (d *Drive) LoadAndWait(ctx context.Context) error {
+	if err := d.Load(); err != nil {
+		return errors.Wrap(err, "load and wait")
+	}
+	if err := d.WaitReady(ctx, DefaultPollInterval); err != nil {
+		return errors.Wrap(err, "load and wait: drive did not become ready")
+	}
+	return nil
+}
+
+// EjectAndWait ejects the tape, then waits until the drive reports no
+// tape, either by the DR_OPEN status bit or status failing with
+// ErrNoMedium, or ctx is done.
+func (d *Drive) EjectAndWait(ctx context.Context) error {
+	if err := d.Eject(); err != nil {
+		return errors.Wrap(err, "eject and wait")
+	}
+	err := pollUntil(ctx, DefaultPollInterval, func() (bool, error) {
+		b, err := d.StatusBits()
+		switch {
+		case errors.Is(err, ErrNoMedium):
+			return true, nil
+		case errors.Is(err, ErrDeviceBusy):
+			return false, nil
+		}
+		return b.DrOpen, err
+	})
+	return errors.Wrap(err, "eject and wait")
+}
+
+// MountOptions configures Mount. Nil fields are left unchanged.
+type MountOptions struct {
+	// BlockSize is set with SetBlockSize, 0 for variable block mode
+	BlockSize *int64
+	// DensityCode is set with SetDensity
+	DensityCode *int64
+	// Compression is set with SetCompression
+	Compression *bool
+	// WaitReady waits for the drive to be ready after loading
+	WaitReady bool
+}
+
+// Mount loads the tape, optionally waits until the drive is ready, applies
+// opts and rewinds. It stops at the first step that fails and the error
+// names that step.
+func (d *Drive) Mount(ctx context.Context, opts MountOptions) error {
+	if err := d.Load(); err != nil {
+		return errors.Wrap(err, "mount: load")
+	}
+	if opts.WaitReady {
+		if err := d.WaitReady(ctx, DefaultPollInterval); err != nil {
+			return errors.Wrap(err, "mount: wait ready")
+		}
+	}
+	if opts.BlockSize != nil {
+		if err := d.SetBlockSize(*opts.BlockSize); err != nil {
+			return errors.Wrap(err, "mount: set block size")
+		}
+	}
+	if opts.DensityCode != nil {
+		if err := d.SetDensity(*opts.DensityCode); err != nil {
+			return errors.Wrap(err, "mount: set density")
+		}
+	}
+	if opts.Compression != nil {
+		if err := d.SetCompression(*opts.Compression); err != nil {
+			return errors.Wrap(err, "mount: set compression")
+		}
+	}
+	if err := d.Rewind(); err != nil {
+		return errors.Wrap(err, "mount: rewind")
+	}
+	return nil
+}
@@ -2,46 +2,261 @@
 Package mt is a Go library for interacting with SCSI magnetic tape drives.
 It wraps the mt executable and parses the output.  The mt executable
 is readily available in most distros.  This library expects compatibility
-with mt-st-1.1 in RedHat flavored distros
+with mt-st-1.1 in RedHat flavored distros by default; other mt
+implementations are supported through a Dialect.
 */
 package mt
 
 import (
-	"io/ioutil"
+	"context"
+	"math"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
 // Drive holds session information when interacting with a magnetic tape drive
 type Drive struct {
+	// lastDur is the duration of the last mt process, accessed
+	// atomically and first for 64-bit alignment on 32-bit platforms
+	lastDur int64
 	// Device is the device file in use for this Drive
 	Device string
+	// ResolvedDevice is Device as an absolute path with symlinks resolved,
+	// set by NewDriveResolved and NewDriveShared and empty otherwise. It
+	// keys the shared device lock. Device is still used for display and
+	// passed to mt.
+	ResolvedDevice string
 	// Command is the mt command used for the Drive
 	Command string
+	// Dialect is the mt implementation Command is expected to be,
+	// nil selects DefaultDialect
+	Dialect *Dialect
+	// Runner runs the mt command, nil runs it with os/exec
+	Runner Runner
+	// MaxRetries is the number of times a command failing with a
+	// transient error, such as ErrDeviceBusy, is retried. Destructive
+	// operations such as erase and weof are never retried.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubled for each
+	// retry after that
+	RetryBackoff time.Duration
+	// BeforeCommand, if set, is called with the arguments to be passed to
+	// mt before it runs. If it returns an error mt is not run and the
+	// error is returned.
+	BeforeCommand func(args []string) error
+	// OnCommand, if set, is called after each mt process exits with the
+	// arguments passed to mt, how long it ran, and the resulting error
+	OnCommand func(args []string, dur time.Duration, err error)
+	// Progress, if set, is called by WriteFile and ReadFileTo after each
+	// record is transferred with the total bytes transferred so far
+	Progress func(bytes int64)
+	// MaxOutput is the most bytes of each of stdout and stderr collected
+	// from an mt process; a command writing more fails with
+	// ErrOutputTooLarge. Zero selects DefaultMaxOutput and a negative
+	// value means no limit.
+	MaxOutput int64
+	// AlwaysRewind, if set, makes RewindIfNeeded always rewind, for
+	// drives whose status cannot be trusted to report the position
+	AlwaysRewind bool
+	// Timeout, if set, limits how long each mt process may run before it
+	// is stopped as described for KillGrace
+	Timeout time.Duration
+	// KillGrace is how long a stopped mt process, on Timeout or its
+	// context being done, is given to exit after SIGTERM before it is
+	// sent SIGKILL, so the drive can finish the current operation
+	// cleanly. Zero selects DefaultKillGrace and a negative value kills
+	// immediately. Custom Runners are not stopped.
+	KillGrace time.Duration
+	// AllowedOps, if non-nil, restricts the Drive to these mt-st operation
+	// keywords, e.g. to rule out erase and weof on a read-only system.
+	// Other operations, including those passed to Raw, fail with
+	// ErrUnsupportedOperation without running mt. Writer and WriteFile,
+	// which write to the device directly, need weof to be allowed.
+	AllowedOps []string
+	// Nice, if non-zero, runs mt under nice with this niceness
+	// adjustment, e.g. 10 to let other work on a busy host go first
+	Nice int
+	// IdleIO, if set, runs mt under ionice in the idle I/O scheduling
+	// class, so it only gets disk time no other process wants. ionice is
+	// only available on Linux.
+	IdleIO bool
+	// DryRun, if set, skips running mt. Commands are still passed to
+	// OnCommand, with a zero duration. Operations that only act on the
+	// tape succeed, Raw and Exec return empty output, and every method
+	// reading mt output, from Status and Tell to StatusInfo and the
+	// pollers built on it, returns ErrDryRun.
+	DryRun bool
+	// ctx is the context set with WithContext
+	ctx context.Context
 	// Protects command exec
 	mu sync.Mutex
+	// devLock, if set, is the device lock shared with other Drives
+	// created with NewDriveShared
+	devLock *sync.Mutex
 }
 
-// NewDrive returns a drive for a given device path
+// DefaultMaxOutput is the output limit used when Drive.MaxOutput is zero.
+// It is far beyond anything mt prints.
+const DefaultMaxOutput = 16 << 20
+
+// DefaultKillGrace is the KillGrace used when Drive.KillGrace is zero.
+const DefaultKillGrace = 5 * time.Second
+
+// MTBinaryEnv is the environment variable naming the mt command used
+// when none is given explicitly.
+const MTBinaryEnv = "MT_BINARY"
+
+// defaultCommand returns the mt command from MTBinaryEnv, or "mt" if it
+// is not set.
+func defaultCommand() string {
+	if cmd := os.Getenv(MTBinaryEnv); cmd != "" {
+		return cmd
+	}
+	return "mt"
+}
+
+// NewDrive returns a drive for a given device path. The mt command is
+// taken from the MT_BINARY environment variable if set, otherwise "mt".
 func NewDrive(device string) *Drive {
-	return &Drive{Device: device, Command: "mt"}
+	return NewDriveOpts(device)
 }
 
-// NewDriveCmd returns a Drive for a given device path and mt command
+// NewDriveCmd returns a Drive for a given device path and mt command.
+// An empty cmd selects the same default as NewDrive, so the order of
+// precedence is cmd, then MT_BINARY, then "mt".
 func NewDriveCmd(device, cmd string) *Drive {
-	return &Drive{Device: device, Command: cmd}
+	return NewDriveOpts(device, WithCommand(cmd))
+}
+
+// NewDriveChecked returns a drive for a given device path after checking
+// that the path exists and is a character device
+func NewDriveChecked(device string) (*Drive, error) {
+	fi, err := os.Stat(device)
+	if err != nil {
+		return nil, errors.Wrap(err, "check device")
+	}
+	if fi.Mode()&os.ModeCharDevice == 0 {
+		return nil, errors.Errorf("check device: %s is not a character device", device)
+	}
+	return NewDrive(device), nil
+}
+
+// NewDriveResolved returns a drive for a given device path like NewDrive,
+// with ResolvedDevice set to the absolute path of device with symlinks
+// resolved, e.g. /dev/nst0 for /dev/tape. Like NewDriveShared its mt
+// commands are serialized with those of every other resolved or shared
+// Drive for the same resolved path. An error is returned if the path
+// cannot be resolved.
+func NewDriveResolved(device string) (*Drive, error) {
+	resolved, err := resolveDevice(device)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolve device")
+	}
+	d := NewDrive(device)
+	d.ResolvedDevice = resolved
+	d.devLock = deviceLock(resolved)
+	return d, nil
+}
+
+// resolveDevice returns device as an absolute path with symlinks resolved.
+func resolveDevice(device string) (string, error) {
+	path, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// devicePath returns ResolvedDevice if set, otherwise Device.
+func (d *Drive) devicePath() string {
+	if d.ResolvedDevice != "" {
+		return d.ResolvedDevice
+	}
+	return d.Device
+}
+
+// NewDriveDialect returns a Drive for a given device path, mt command and
+// mt dialect
+func NewDriveDialect(device, cmd string, dialect *Dialect) *Drive {
+	d := NewDriveCmd(device, cmd)
+	d.Dialect = dialect
+	return d
+}
+
+// Clone returns a new Drive with the same configuration as d and its own
+// lock. Operations on clones are not serialized with each other, so the
+// caller must make sure clones of the same device do not issue conflicting
+// operations. Clones of a Drive from NewDriveShared keep its shared device
+// lock, so their individual mt commands are still serialized.
+func (d *Drive) Clone() *Drive {
+	return &Drive{
+		Device:         d.Device,
+		ResolvedDevice: d.ResolvedDevice,
+		Command:        d.Command,
+		Dialect:        d.Dialect,
+		Runner:         d.Runner,
+		MaxRetries:     d.MaxRetries,
+		RetryBackoff:   d.RetryBackoff,
+		BeforeCommand:  d.BeforeCommand,
+		OnCommand:      d.OnCommand,
+		Progress:       d.Progress,
+		MaxOutput:      d.MaxOutput,
+		AlwaysRewind:   d.AlwaysRewind,
+		Timeout:        d.Timeout,
+		KillGrace:      d.KillGrace,
+		AllowedOps:     d.AllowedOps,
+		Nice:           d.Nice,
+		IdleIO:         d.IdleIO,
+		DryRun:         d.DryRun,
+		ctx:            d.ctx,
+		devLock:        d.devLock,
+	}
+}
+
+// Available looks up the mt command cmd in PATH, or checks it directly if
+// it contains a slash, and returns its resolved path. No device is
+// accessed.
+func Available(cmd string) (string, error) {
+	path, err := exec.LookPath(cmd)
+	if err != nil {
+		return "", errors.Wrapf(err, "mt command %q not available", cmd)
+	}
+	return path, nil
+}
+
+// CheckBinary returns the resolved path of the Drive's mt command, or an
+// error if it is not available.
+func (d *Drive) CheckBinary() (string, error) {
+	return Available(d.Command)
+}
+
+// String returns the Drive's command and device, e.g. mt(/dev/nst0).
+func (d *Drive) String() string {
+	return d.Command + "(" + d.Device + ")"
 }
 
 // ForwardFiles forward space n files.
 // The tape is positioned on the first block of the next file.
+// A count of 0 is a no-op and does not run mt.
 func (d *Drive) ForwardFiles(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "fsf")
+	}
+	if n == 0 {
+		return nil
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "fsf", strconv.FormatInt(n, 10))
+	_, err := d.run("fsf", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "fsf")
 }
 
@@ -49,19 +264,33 @@ func (d *Drive) ForwardFiles(n int64) error {
 // then backward space one file record.
 // This leaves the tape positioned on the
 // last block of the file that is n-1 files past the current file.
+// A count of 0 is a no-op and does not run mt.
 func (d *Drive) ForwardFileMarks(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "fsfm")
+	}
+	if n == 0 {
+		return nil
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "fsfm", strconv.FormatInt(n, 10))
+	_, err := d.run("fsfm", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "fsfm")
 }
 
 // BackwardFiles backward space n files.
 // The tape is positioned on the last block of the previous file.
+// A count of 0 is a no-op and does not run mt.
 func (d *Drive) BackwardFiles(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "bsf")
+	}
+	if n == 0 {
+		return nil
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "bsf", strconv.FormatInt(n, 10))
+	_, err := d.run("bsf", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "bsf")
 }
 
@@ -69,53 +298,124 @@ func (d *Drive) BackwardFiles(n int64) error {
 // then forward space one file record.
 // This leaves the tape positioned on the first block of
 // the file that is n-1 files before the current file.
+// On FreeBSD this is the nbsf operation.
+// A count of 0 is a no-op and does not run mt.
 func (d *Drive) BackwardFileMarks(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "bsfm")
+	}
+	if n == 0 {
+		return nil
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "bsfm", strconv.FormatInt(n, 10))
+	_, err := d.run("bsfm", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "bsfm")
 }
 
+// NextFile forward space one file, the same as ForwardFiles(1).
+func (d *Drive) NextFile() error {
+	return d.ForwardFiles(1)
+}
+
+// PrevFile backward space one file, the same as BackwardFiles(1).
+func (d *Drive) PrevFile() error {
+	return d.BackwardFiles(1)
+}
+
 // PositionToFile the tape is positioned at the beginning of
 // the nth file.
 // Positioning is done by first rewinding the tape and then
 // spacing forward over n filemarks.
 func (d *Drive) PositionToFile(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "asf")
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "asf", strconv.FormatInt(n, 10))
+	_, err := d.run("asf", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "asf")
 }
 
+// PositionToBlockInFile positions the tape at the beginning of the given
+// file, then forward spaces record records. The Drive is locked across
+// both steps so no other operation can run in between.
+func (d *Drive) PositionToBlockInFile(file, record int64) error {
+	if err := checkCount(file); err != nil {
+		return errors.Wrap(err, "asf")
+	}
+	if err := checkCount(record); err != nil {
+		return errors.Wrap(err, "fsr")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, err := d.run("asf", strconv.FormatInt(file, 10)); err != nil {
+		return errors.Wrap(err, "asf")
+	}
+	if record == 0 {
+		return nil
+	}
+	_, err := d.run("fsr", strconv.FormatInt(record, 10))
+	return errors.Wrap(err, "fsr")
+}
+
 // ForwardRecords forward space n records.
+// A count of 0 is a no-op and does not run mt.
 func (d *Drive) ForwardRecords(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "fsr")
+	}
+	if n == 0 {
+		return nil
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "fsr", strconv.FormatInt(n, 10))
+	_, err := d.run("fsr", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "fsr")
 }
 
 // BackwardRecords backward space n records.
+// A count of 0 is a no-op and does not run mt.
 func (d *Drive) BackwardRecords(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "bsr")
+	}
+	if n == 0 {
+		return nil
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "bsr", strconv.FormatInt(n, 10))
+	_, err := d.run("bsr", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "bsr")
 }
 
 // ForwardSetMarks (SCSI tapes) forward space n setmarks.
+// A count of 0 is a no-op and does not run mt.
 func (d *Drive) ForwardSetMarks(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "fss")
+	}
+	if n == 0 {
+		return nil
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "fss", strconv.FormatInt(n, 10))
+	_, err := d.run("fss", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "fss")
 }
 
 // BackwardSetMarks (SCSI tapes) backward space n setmarks.
+// A count of 0 is a no-op and does not run mt.
 func (d *Drive) BackwardSetMarks(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "bss")
+	}
+	if n == 0 {
+		return nil
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "bss", strconv.FormatInt(n, 10))
+	_, err := d.run("bss", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "bss")
 }
 
@@ -125,7 +425,7 @@ func (d *Drive) BackwardSetMarks(n int64) error {
 func (d *Drive) PositionEOD() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "eod")
+	_, err := d.run("eod")
 	return errors.Wrap(err, "eod")
 }
 
@@ -133,17 +433,47 @@ func (d *Drive) PositionEOD() error {
 func (d *Drive) Rewind() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "rewind")
+	_, err := d.run("rewind")
 	return errors.Wrap(err, "rewind")
 }
 
+// RewindIfNeeded rewinds the tape unless AtStart reports it is already
+// at the start, saving the rewind when it is. With AlwaysRewind set it
+// always rewinds without checking.
+func (d *Drive) RewindIfNeeded() error {
+	if !d.AlwaysRewind {
+		start, err := d.AtStart()
+		if err != nil {
+			return errors.Wrap(err, "rewind")
+		}
+		if start {
+			return nil
+		}
+	}
+	return d.Rewind()
+}
+
 // Eject will rewind the tape and, if applicable,
-// unload the tape.
+// unload the tape. A drive that is busy fails with ErrDeviceBusy, which
+// is retried according to MaxRetries, while a cartridge that cannot be
+// unloaded fails with ErrMechanicalFailure.
 func (d *Drive) Eject() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "eject")
-	return errors.Wrap(err, "eject")
+	_, err := d.run("eject")
+	return errors.Wrap(matchEject(err), "eject")
+}
+
+// Offline will rewind the tape and take the drive offline.
+// Eject asks for the cartridge to be unloaded as well, while offline only
+// guarantees the unit is placed offline. mt-st issues the same request for
+// both, but other mt variants distinguish them, so use Offline when the
+// cartridge should stay in the drive where possible.
+func (d *Drive) Offline() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.run("offline")
+	return errors.Wrap(err, "offline")
 }
 
 // Retension will wewind the tape, then wind it to the
@@ -151,24 +481,42 @@ func (d *Drive) Eject() error {
 func (d *Drive) Retension() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "retension")
+	_, err := d.run("retension")
 	return errors.Wrap(err, "retension")
 }
 
 // WriteEOFMarks write n EOF marks at current position.
 func (d *Drive) WriteEOFMarks(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "weof")
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "weof", strconv.FormatInt(n, 10))
+	_, err := d.run("weof", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "weof")
 }
 
+// Sync forces data buffered in the drive to be written to tape by writing
+// zero filemarks, weof 0, which all dialects pass to the driver as a
+// write filemarks command with a count of 0. SCSI drives flush their
+// buffer on it without writing anything, so when Sync returns the data
+// written so far is on tape. The tape position is not changed.
+func (d *Drive) Sync() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.run("weof", "0")
+	return errors.Wrap(err, "sync")
+}
+
 // WriteSetMarks (SCSI tapes) Write n setmarks at
 // current position (only SCSI tape).
 func (d *Drive) WriteSetMarks(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "wset")
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "wset", strconv.FormatInt(n, 10))
+	_, err := d.run("wset", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "wset")
 }
 
@@ -176,7 +524,30 @@ func (d *Drive) WriteSetMarks(n int64) error {
 func (d *Drive) Erase() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "erase")
+	_, err := d.run("erase")
+	return errors.Wrap(err, "erase")
+}
+
+// EraseShort erase the tape with a short erase, which on most drives only
+// writes an end of data mark at the current position.
+func (d *Drive) EraseShort() error {
+	return d.eraseArg(d.dialect().ShortErase)
+}
+
+// EraseLong erase the tape with a long erase, overwriting the whole tape.
+// A long erase can take hours on large tapes.
+func (d *Drive) EraseLong() error {
+	return d.eraseArg(d.dialect().LongErase)
+}
+
+func (d *Drive) eraseArg(arg string) error {
+	if arg == "" {
+		return errors.Wrapf(ErrUnsupportedOperation, "erase: %s mt does not select erase type",
+			d.dialect().Name)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.run("erase", arg)
 	return errors.Wrap(err, "erase")
 }
 
@@ -184,51 +555,137 @@ func (d *Drive) Erase() error {
 func (d *Drive) Status() (string, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	result, err := mtCmd(d.Command, d.Device, "status")
+	result, err := d.output("status")
 	if err != nil {
 		return "", errors.Wrap(err, "status")
 	}
 	return string(result[:]), nil
 }
 
+// StatusVerbose is Status also returning what mt wrote to stderr, such as
+// warnings printed by a status that succeeds. The stderr is only
+// collected with the default Runner or a StderrRunner.
+func (d *Drive) StatusVerbose() (stdout, stderr string, err error) {
+	kw, err := d.keyword("status")
+	if err != nil {
+		return "", "", errors.Wrap(err, "status")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out, serr, err := d.execArgsStderr([]string{"-f", d.Device, kw})
+	if err == nil && d.DryRun {
+		err = ErrDryRun
+	}
+	if err != nil {
+		return "", string(serr), errors.Wrap(err, "status")
+	}
+	return string(out), string(serr), nil
+}
+
 // SeekTape (SCSI tapes) seek to the nth block on the tape.
 func (d *Drive) SeekTape(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "seek")
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "seek", strconv.FormatInt(n, 10))
+	_, err := d.run("seek", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "seek")
 }
 
+// SeekLogical (SCSI tapes) seek to the nth SCSI-2 logical block on the
+// tape. SeekTape and Tell use device dependent block addresses unless the
+// scsi2logical driver option is set, in which case they use SCSI-2
+// logical block addresses, which count blocks and filemarks from the
+// beginning of the partition. If setOption is true the scsi2logical
+// option is set before seeking; it stays set for later SeekTape and Tell
+// calls, and the other driver options are left unchanged. If setOption is
+// false the caller must already have set it.
+func (d *Drive) SeekLogical(n int64, setOption bool) error {
+	if setOption {
+		if err := d.StSetSelectedOptions(OptionSCSI2Logical); err != nil {
+			return errors.Wrap(err, "seek logical")
+		}
+	}
+	return d.SeekTape(n)
+}
+
 // Tell (SCSI tapes) tell the current block on tape.
 func (d *Drive) Tell() (string, error) {
 	// TODO: return int64 instead of string
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	result, err := mtCmd(d.Command, d.Device, "tell")
+	result, err := d.output("tell")
 	if err != nil {
 		return "", errors.Wrap(err, "tell")
 	}
 	return string(result[:]), nil
 }
 
+var tellRe = regexp.MustCompile(`(\d+)`)
+
+// TellBlock (SCSI tapes) returns the current block on tape as a number.
+// The first number in the tell output is used, which covers both mt-st
+// "At block N." and the bare number printed by other dialects.
+func (d *Drive) TellBlock() (int64, error) {
+	out, err := d.Tell()
+	if err != nil {
+		return 0, err
+	}
+	m := tellRe.FindStringSubmatch(out)
+	if m == nil {
+		return 0, errors.Errorf("tell: unexpected output %q", strings.TrimSpace(out))
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "tell")
+	}
+	return n, nil
+}
+
 // SetPartition (SCSI tapes) Switch to the nth partition. The
 // default data partition of the tape is numbered zero. Switching
 // partition  is available only if enabled for the device, the device
 // supports multiple partitions, and the tape is formatted  with  multiple
 // partitions.
 func (d *Drive) SetPartition(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "setpartition")
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "setpartition", strconv.FormatInt(n, 10))
+	_, err := d.run("setpartition", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "setpartition")
 }
 
 // SeekPartition (SCSI tapes) the tape position is set to nth block in the
-// partition given by the argument.
+// partition given by the argument. When status reports the number of
+// partitions, see PartitionCount, a partition beyond it is rejected
+// without running partseek; if status fails or does not report the count,
+// as with mt-st, the seek is attempted anyway.
 func (d *Drive) SeekPartition(n, part int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "partseek")
+	}
+	if err := checkCount(part); err != nil {
+		return errors.Wrap(err, "partseek")
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "partseek",
+	if out, err := d.run("status"); err == nil {
+		info, err := d.dialect().ParseStatus(string(out))
+		if err == nil && info.Partitions >= 0 && part >= info.Partitions {
+			return errors.Errorf("partseek: partition %d out of range, tape has %d partitions",
+				part, info.Partitions)
+		}
+	}
+	return d.seekPartition(n, part)
+}
+
+// seekPartition runs partseek without the partition check. The Drive
+// must be locked.
+func (d *Drive) seekPartition(n, part int64) error {
+	_, err := d.run("partseek",
 		strconv.FormatInt(n, 10), strconv.FormatInt(part, 10))
 	return errors.Wrap(err, "partseek")
 }
@@ -238,9 +695,12 @@ func (d *Drive) SeekPartition(n, part int64) error {
 // The tape drive must be able to format partitioned tapes with initiator
 // specified partition size and partition support must be enabled for the drive.
 func (d *Drive) MakePartition(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "mkpartition")
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "mkpartition", strconv.FormatInt(n, 10))
+	_, err := d.run("mkpartition", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "mkpartition")
 }
 
@@ -249,7 +709,7 @@ func (d *Drive) MakePartition(n int64) error {
 func (d *Drive) Load() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "load")
+	_, err := d.run("load")
 	return errors.Wrap(err, "load")
 }
 
@@ -257,7 +717,7 @@ func (d *Drive) Load() error {
 func (d *Drive) Lock() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "lock")
+	_, err := d.run("lock")
 	return errors.Wrap(err, "lock")
 }
 
@@ -265,26 +725,50 @@ func (d *Drive) Lock() error {
 func (d *Drive) Unlock() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "unlock")
+	_, err := d.run("unlock")
 	return errors.Wrap(err, "unlock")
 }
 
 // SetBlockSize (SCSI tapes) set the blocksize of the
 // drive to n bytes per record.
 func (d *Drive) SetBlockSize(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "setblk")
+	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "setblk", strconv.FormatInt(n, 10))
+	_, err := d.run("setblk", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "setblk")
 }
 
+// SetVariableBlockMode (SCSI tapes) set the drive to variable block mode,
+// the same as SetBlockSize(0).
+func (d *Drive) SetVariableBlockMode() error {
+	return d.SetBlockSize(0)
+}
+
+// SetFixedBlockMode (SCSI tapes) set the drive to fixed block mode with
+// n bytes per record, the same as SetBlockSize(n).
+func (d *Drive) SetFixedBlockMode(n int64) error {
+	return d.SetBlockSize(n)
+}
+
+// IsVariableBlockMode reports whether the drive is in variable block mode.
+func (d *Drive) IsVariableBlockMode() (bool, error) {
+	n, err := d.BlockSize()
+	if err != nil {
+		return false, err
+	}
+	return n == 0, nil
+}
+
 // SetDensity (SCSI tapes) set the tape density code to n.
 // The proper codes to use with each drive should be looked
 // up from the drive documentation.
 func (d *Drive) SetDensity(n int64) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "setdensity", strconv.FormatInt(n, 10))
+	_, err := d.run("setdensity", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "setdensity")
 }
 
@@ -295,7 +779,7 @@ func (d *Drive) SetDensity(n int64) error {
 func (d *Drive) SetDriveBuffer(n int) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "drvbuffer", strconv.Itoa(n))
+	_, err := d.run("drvbuffer", strconv.Itoa(n))
 	return errors.Wrap(err, "drvbuffer")
 }
 
@@ -312,10 +796,28 @@ func (d *Drive) SetCompression(enable bool) error {
 	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "compression", state)
+	_, err := d.run("compression", state)
 	return errors.Wrap(err, "compression")
 }
 
+// SetDataCompression (DAT tapes) switch the compression of DDS/DAT drives
+// on or off with the datcompression operation, which sets the DAT
+// compression mode page rather than using the MTCOMPRESSION ioctl like
+// SetCompression. Both may be available on the same drive.
+// arguments: true to enable, false to disable
+func (d *Drive) SetDataCompression(enable bool) error {
+	var state string
+	if enable {
+		state = "1"
+	} else {
+		state = "0"
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.run("datcompression", state)
+	return errors.Wrap(err, "datcompression")
+}
+
 // StSetOptions (SCSI tapes) set the driver options bits for the device to the
 // defined values. The bits can be set either by ORing the option bits from
 // the file /usr/include/linux/mtio.h and passing in as a string, or by using
@@ -341,21 +843,32 @@ func (d *Drive) SetCompression(enable bool) error {
 //                  returns transfer residual byte counts. Requires  ker-
 //                  nel version >= 2.6.26.
 //   sysv           enable the System V semantics
-func (d *Drive) StSetOptions(args ...string) error {
-	optargs := append([]string{"stoptions"}, args...)
+// Each keyword has an STOption constant. A numeric bit mask can be passed
+// as an STOption. This replaces the whole option word, clearing every
+// option not given; StSetSelectedOptions sets options leaving the others.
+func (d *Drive) StSetOptions(opts ...STOption) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, optargs...)
+	_, err := d.run("stoptions", optionArgs(opts)...)
 	return errors.Wrap(err, "stoptions")
 }
 
+// StSetSelectedOptions (SCSI tapes) set selected driver option bits,
+// leaving the others unchanged. The methods to specify the bits to set are
+// given above in description of StSetOptions.
+func (d *Drive) StSetSelectedOptions(opts ...STOption) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.run("stsetoptions", optionArgs(opts)...)
+	return errors.Wrap(err, "stsetoptions")
+}
+
 // StClearOptions (SCSI tapes) clear selected driver option bits. The methods to
 // specify the bits to clear are given above in description of StSetOptions.
-func (d *Drive) StClearOptions(args ...string) error {
-	optargs := append([]string{"stclearoptions"}, args...)
+func (d *Drive) StClearOptions(opts ...STOption) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, optargs...)
+	_, err := d.run("stclearoptions", optionArgs(opts)...)
 	return errors.Wrap(err, "stclearoptions")
 }
 
@@ -365,7 +878,7 @@ func (d *Drive) StShowOptions() (string, error) {
 	// TODO: return []string options
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	result, err := mtCmd(d.Command, d.Device, "stshowopt")
+	result, err := d.output("stshowopt")
 	if err != nil {
 		return "", errors.Wrap(err, "stshowopt")
 	}
@@ -379,7 +892,7 @@ func (d *Drive) StShowOptions() (string, error) {
 func (d *Drive) SetWriteThreashold(n int64) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "stwrthreshold", strconv.FormatInt(n, 10))
+	_, err := d.run("stwrthreshold", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "stwrthreshold")
 }
 
@@ -389,7 +902,7 @@ func (d *Drive) SetWriteThreashold(n int64) error {
 func (d *Drive) SetDefaultBlockSize(n int64) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "defblksize", strconv.FormatInt(n, 10))
+	_, err := d.run("defblksize", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "defblksize")
 }
 
@@ -399,7 +912,7 @@ func (d *Drive) SetDefaultBlockSize(n int64) error {
 func (d *Drive) SetDefaultDensity(n int64) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "defdensity", strconv.FormatInt(n, 10))
+	_, err := d.run("defdensity", strconv.FormatInt(n, 10))
 	return errors.Wrap(err, "defdensity")
 }
 
@@ -409,7 +922,7 @@ func (d *Drive) SetDefaultDensity(n int64) error {
 func (d *Drive) SetDefaultDriveBuffer(n int) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "defdrvbuffer", strconv.Itoa(n))
+	_, err := d.run("defdrvbuffer", strconv.Itoa(n))
 	return errors.Wrap(err, "defdrvbuffer")
 }
 
@@ -425,7 +938,7 @@ func (d *Drive) SetDefaultCompression(enable bool) error {
 	}
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "defcompression", state)
+	_, err := d.run("defcompression", state)
 	return errors.Wrap(err, "defcompression")
 }
 
@@ -434,7 +947,7 @@ func (d *Drive) DisableDefaultCompression() error {
 	state := "-1"
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "defcompression", state)
+	_, err := d.run("defcompression", state)
 	return errors.Wrap(err, "defcompression")
 }
 
@@ -443,7 +956,7 @@ func (d *Drive) DisableDefaultCompression() error {
 func (d *Drive) SetTimeout(n int) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "sttimeout", strconv.Itoa(n))
+	_, err := d.run("sttimeout", strconv.Itoa(n))
 	return errors.Wrap(err, "sttimeout")
 }
 
@@ -452,45 +965,314 @@ func (d *Drive) SetTimeout(n int) error {
 func (d *Drive) SetLongTimeout(n int) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "stlongtimeout", strconv.Itoa(n))
+	_, err := d.run("stlongtimeout", strconv.Itoa(n))
 	return errors.Wrap(err, "stlongtimeout")
 }
 
+// Reset resets the drive with the dialect's reset operation, as a
+// recovery step for a drive in a bad state. None of the built in dialects
+// have one, so ErrUnsupportedOperation is returned unless the Drive uses a
+// Dialect mapping "reset" to a keyword.
+func (d *Drive) Reset() error {
+	if !d.Supports("reset") {
+		return errors.Wrapf(ErrUnsupportedOperation, "reset: %s mt", d.dialect().Name)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.run("reset")
+	return errors.Wrap(err, "reset")
+}
+
+// ListDensities (mt-st) returns the list of density codes and names
+// known to mt-st. The list is built into mt, it is not reported by the
+// drive.
+func (d *Drive) ListDensities() (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result, err := d.output("densities")
+	if err != nil {
+		return "", errors.Wrap(err, "densities")
+	}
+	return string(result[:]), nil
+}
+
+// WriteEOFMarksImmediate (FreeBSD) write n EOF marks at current position
+// without waiting for buffered data to be written to tape.
+func (d *Drive) WriteEOFMarksImmediate(n int64) error {
+	if err := checkCount(n); err != nil {
+		return errors.Wrap(err, "weofi")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, err := d.run("weofi", strconv.FormatInt(n, 10))
+	return errors.Wrap(err, "weofi")
+}
+
+// ErrorStatus (FreeBSD) returns the error status and sense data of the
+// last command, as printed by mt errstat.
+func (d *Drive) ErrorStatus() (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result, err := d.output("errstat")
+	if err != nil {
+		return "", errors.Wrap(err, "errstat")
+	}
+	return string(result[:]), nil
+}
+
 // SetClean set the cleaning request interpretation parameters.
 func (d *Drive) SetClean() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	_, err := mtCmd(d.Command, d.Device, "stsetcln")
+	_, err := d.run("stsetcln")
 	return errors.Wrap(err, "stsetcln")
 }
 
-func mtCmd(mtcmd, dev string, args ...string) ([]byte, error) {
-	cmdargs := append([]string{"-f", dev}, args...)
-	cmd := exec.Command(mtcmd, cmdargs...)
-	stdout, err := cmd.StdoutPipe()
-	stderr, err := cmd.StderrPipe()
+// Raw runs the mt command with arbitrary arguments and returns the raw
+// output. The device argument is supplied from the Drive, so args should
+// not include -f. This bypasses all argument checking and output parsing
+// done by the other methods and is intended for operations this package
+// does not wrap.
+func (d *Drive) Raw(args ...string) ([]byte, error) {
+	if err := d.checkRaw(args); err != nil {
+		return nil, errors.Wrap(err, "raw")
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result, err := d.mtCmd(args...)
 	if err != nil {
-		err = errors.Wrap(err, "mt command setup stderr pipe")
-		return []byte{}, err
+		return nil, errors.Wrap(err, "raw")
 	}
-	if err := cmd.Start(); err != nil {
-		err = errors.Wrap(err, "mt start command")
-		return []byte{}, err
+	return result, nil
+}
+
+// checkCount returns an error if n is not a valid mt count or position.
+// mt takes these as a C int, so values above math.MaxInt32 are rejected
+// along with negative values.
+func checkCount(n int64) error {
+	if n < 0 {
+		return errors.Errorf("invalid negative count %d", n)
 	}
-	cmdout, err := ioutil.ReadAll(stdout)
-	if err != nil {
-		err = errors.Wrap(err, "mt read stdout output")
-		return []byte{}, err
+	if n > math.MaxInt32 {
+		return errors.Errorf("count %d out of range", n)
+	}
+	return nil
+}
+
+// LastCommandDuration returns how long the last mt process run by the
+// Drive took. Only the process execution is timed, not waiting for the
+// Drive lock or retry backoff.
+func (d *Drive) LastCommandDuration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&d.lastDur))
+}
+
+// keyword returns the keyword for op in the Drive's dialect, or
+// ErrUnsupportedOperation if op is not in AllowedOps.
+func (d *Drive) keyword(op string) (string, error) {
+	if !d.allowed(op) {
+		return "", errors.Wrapf(ErrUnsupportedOperation, "%s: not an allowed operation", op)
 	}
-	cmderr, err := ioutil.ReadAll(stderr)
+	return d.dialect().keyword(op)
+}
+
+// allowed reports whether op is in AllowedOps, true if AllowedOps is nil.
+func (d *Drive) allowed(op string) bool {
+	if d.AllowedOps == nil {
+		return true
+	}
+	for _, a := range d.AllowedOps {
+		if a == op {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRaw returns ErrUnsupportedOperation if AllowedOps is set and an
+// argument to Raw that is not a number is neither an allowed operation
+// nor the dialect keyword of one.
+func (d *Drive) checkRaw(args []string) error {
+	if d.AllowedOps == nil {
+		return nil
+	}
+	for _, arg := range args {
+		if _, err := strconv.ParseInt(arg, 0, 64); err == nil {
+			continue
+		}
+		ok := false
+		for _, op := range d.AllowedOps {
+			if kw, err := d.dialect().keyword(op); op == arg || (err == nil && kw == arg) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return errors.Wrapf(ErrUnsupportedOperation, "%s: not an allowed operation", arg)
+		}
+	}
+	return nil
+}
+
+// run runs op with args, translating op to the keyword of the Drive's
+// dialect.
+func (d *Drive) run(op string, args ...string) ([]byte, error) {
+	kw, err := d.keyword(op)
 	if err != nil {
-		err = errors.Wrap(err, "mt read stderr output")
 		return []byte{}, err
 	}
-	if err := cmd.Wait(); err != nil {
-		err = errors.Wrap(err, "mt wait command")
-		err = errors.Wrap(err, strings.TrimSuffix(string(cmderr), "\n"))
-		return []byte{}, err
+	return d.mtCmd(append([]string{kw}, args...)...)
+}
+
+// output is run for an operation whose output is read. With DryRun the
+// command is still passed to OnCommand, but ErrDryRun is returned since
+// there is no output.
+func (d *Drive) output(op string, args ...string) ([]byte, error) {
+	result, err := d.run(op, args...)
+	if err == nil && d.DryRun {
+		return []byte{}, ErrDryRun
+	}
+	return result, err
+}
+
+// mtCmd runs the mt command on the Drive's device with args, retrying
+// transient failures up to MaxRetries times.
+func (d *Drive) mtCmd(args ...string) ([]byte, error) {
+	return d.execArgs(append([]string{"-f", d.Device}, args...))
+}
+
+// execArgs runs the mt command with the complete argument list cmdargs.
+func (d *Drive) execArgs(cmdargs []string) ([]byte, error) {
+	out, _, err := d.execArgsStderr(cmdargs)
+	return out, err
+}
+
+// execArgsStderr is execArgs also returning the stderr of the last
+// attempt, which is empty unless the Runner is a StderrRunner.
+func (d *Drive) execArgsStderr(cmdargs []string) ([]byte, []byte, error) {
+	max := d.maxOutput()
+	if d.BeforeCommand != nil {
+		if err := d.BeforeCommand(cmdargs); err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "mt command rejected")
+		}
+	}
+	if d.DryRun {
+		if d.OnCommand != nil {
+			d.OnCommand(cmdargs, 0, nil)
+		}
+		return []byte{}, []byte{}, nil
+	}
+	backoff := d.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		// the lock is taken first so that waiting for another Drive on
+		// the device counts toward neither Timeout nor the duration
+		if d.devLock != nil {
+			d.devLock.Lock()
+		}
+		ctx, cancel := d.commandContext()
+		runner := d.Runner
+		if runner == nil {
+			runner = execRunner{
+				maxOutput: max,
+				ctx:       ctx,
+				killGrace: d.killGrace(),
+				nice:      d.Nice,
+				idleIO:    d.IdleIO,
+			}
+		}
+		start := time.Now()
+		out, stderr, err := runStderr(runner, d.Command, cmdargs)
+		dur := time.Since(start)
+		cancel()
+		if d.devLock != nil {
+			d.devLock.Unlock()
+		}
+		if err == nil && max > 0 && (int64(len(out)) > max || int64(len(stderr)) > max) {
+			out, stderr = []byte{}, []byte{}
+			err = errors.Wrapf(ErrOutputTooLarge, "mt command: more than %d bytes", max)
+		}
+		atomic.StoreInt64(&d.lastDur, int64(dur))
+		var cmdErr *CommandError
+		if errors.As(err, &cmdErr) {
+			cmdErr.parseStderr()
+			err = matchStderr(err, cmdErr.Stderr)
+		}
+		if d.OnCommand != nil {
+			d.OnCommand(cmdargs, dur, err)
+		}
+		if err == nil {
+			return out, stderr, nil
+		}
+		if attempt >= d.MaxRetries || !isTransient(err) || !retryable(cmdargs) {
+			return []byte{}, stderr, err
+		}
+		select {
+		case <-d.context().Done():
+			return []byte{}, stderr, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// context returns the Drive's context, context.Background if none was
+// set with WithContext.
+func (d *Drive) context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
+// commandContext returns the context for one mt process, the Drive's
+// context limited by Timeout.
+func (d *Drive) commandContext() (context.Context, context.CancelFunc) {
+	if d.Timeout > 0 {
+		return context.WithTimeout(d.context(), d.Timeout)
+	}
+	return context.WithCancel(d.context())
+}
+
+// killGrace returns the time between SIGTERM and SIGKILL, 0 to kill
+// immediately.
+func (d *Drive) killGrace() time.Duration {
+	switch {
+	case d.KillGrace == 0:
+		return DefaultKillGrace
+	case d.KillGrace < 0:
+		return 0
+	}
+	return d.KillGrace
+}
+
+// WithContext returns a clone of d whose mt processes are stopped when
+// ctx is done, as described for KillGrace. Like Clone, the returned
+// Drive has its own lock. ctx must be non-nil.
+func (d *Drive) WithContext(ctx context.Context) *Drive {
+	if ctx == nil {
+		panic("mt: nil context")
+	}
+	c := d.Clone()
+	c.ctx = ctx
+	return c
+}
+
+// maxOutput returns the output limit, 0 for no limit.
+func (d *Drive) maxOutput() int64 {
+	switch {
+	case d.MaxOutput == 0:
+		return DefaultMaxOutput
+	case d.MaxOutput < 0:
+		return 0
+	}
+	return d.MaxOutput
+}
+
+// runStderr runs the command with runner, returning its stderr if runner
+// is a StderrRunner.
+func runStderr(runner Runner, name string, args []string) ([]byte, []byte, error) {
+	if sr, ok := runner.(StderrRunner); ok {
+		return sr.RunStderr(name, args)
 	}
-	return cmdout, nil
+	out, err := runner.Run(name, args)
+	return out, []byte{}, err
 }
@@ -0,0 +1,677 @@
+package mt_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benmcclelland/mt"
+	"github.com/benmcclelland/mt/mttest"
+	"github.com/pkg/errors"
+)
+
+func TestParseGeneralStatusBitsOnline(t *testing.T) {
+	tests := []struct {
+		name   string
+		out    string
+		online bool
+		drOpen bool
+		bot    bool
+	}{
+		{"online", mttest.StatusOnline, true, false, true},
+		{"no tape", mttest.StatusNoTape, false, true, false},
+		{"no bits", "File number=0, block number=0, partition=0.\n", false, false, false},
+	}
+	for _, tt := range tests {
+		b := mt.ParseGeneralStatusBits(tt.out)
+		if b.Online != tt.online || b.DrOpen != tt.drOpen || b.BOT != tt.bot {
+			t.Errorf("%s: got online %t dr_open %t bot %t", tt.name, b.Online, b.DrOpen, b.BOT)
+		}
+		if b.Flags.Has(mt.FlagOnline) != tt.online {
+			t.Errorf("%s: Flags %v", tt.name, b.Flags)
+		}
+	}
+}
+
+func TestIsOnline(t *testing.T) {
+	for _, tt := range []struct {
+		out  string
+		want bool
+	}{
+		{mttest.StatusOnline, true},
+		{mttest.StatusNoTape, false},
+	} {
+		f := mttest.NewFakeRunner()
+		f.Set("status", mttest.Response{Stdout: tt.out})
+		got, err := f.Drive("/dev/nst0").IsOnline()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("IsOnline = %t, want %t", got, tt.want)
+		}
+	}
+}
+
+func TestCleaningRequired(t *testing.T) {
+	for _, tt := range []struct {
+		out  string
+		want bool
+	}{
+		{mttest.StatusCleaning, true},
+		{mttest.StatusOnline, false},
+		// the raw value alone reports the CLN bit
+		{"General status bits on (00008000):\n", true},
+	} {
+		f := mttest.NewFakeRunner()
+		f.Set("status", mttest.Response{Stdout: tt.out})
+		got, err := f.Drive("/dev/nst0").CleaningRequired()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("CleaningRequired(%q) = %t, want %t", tt.out, got, tt.want)
+		}
+	}
+}
+
+var busy = mttest.Response{Stderr: "mt: /dev/nst0: Device or resource busy\n", ExitCode: 2}
+
+func TestRetryTransient(t *testing.T) {
+	f := mttest.NewFakeRunner()
+	f.Set("rewind", busy, busy, mttest.Response{})
+	d := f.Drive("/dev/nst0")
+	d.MaxRetries = 2
+	if err := d.Rewind(); err != nil {
+		t.Fatalf("Rewind: %v", err)
+	}
+	if n := len(f.Calls()); n != 3 {
+		t.Errorf("ran mt %d times, want 3", n)
+	}
+}
+
+func TestRetryNotReady(t *testing.T) {
+	notReady := mttest.Response{
+		Stderr:   "st 2:0:0:0: [st0] Add. Sense: Logical unit is in process of becoming ready\nmt: /dev/nst0: Input/output error\n",
+		ExitCode: 2,
+	}
+	f := mttest.NewFakeRunner()
+	f.Set("rewind", notReady, mttest.Response{})
+	d := f.Drive("/dev/nst0")
+	d.MaxRetries = 1
+	if err := d.Rewind(); err != nil {
+		t.Fatalf("Rewind: %v", err)
+	}
+
+	f.Set("rewind", mttest.Response{Stderr: "mt: /dev/nst0: not ready, medium not present\n", ExitCode: 2})
+	if err := d.Rewind(); !errors.Is(err, mt.ErrNoMedium) || errors.Is(err, mt.ErrDriveNotReady) {
+		t.Errorf("Rewind = %v, want ErrNoMedium", err)
+	}
+	if n := len(f.Calls()); n != 3 {
+		t.Errorf("ran mt %d times, want 3", n)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	f := mttest.NewFakeRunner()
+	f.Set("rewind", busy, busy, mttest.Response{})
+	d := f.Drive("/dev/nst0")
+	d.MaxRetries = 1
+	if err := d.Rewind(); !errors.Is(err, mt.ErrDeviceBusy) {
+		t.Fatalf("Rewind = %v, want ErrDeviceBusy", err)
+	}
+	if n := len(f.Calls()); n != 2 {
+		t.Errorf("ran mt %d times, want 2", n)
+	}
+}
+
+func TestNoRetryDestructive(t *testing.T) {
+	for name, op := range map[string]func(*mt.Drive) error{
+		"weof":  func(d *mt.Drive) error { return d.WriteEOFMarks(1) },
+		"erase": (*mt.Drive).Erase,
+	} {
+		f := mttest.NewFakeRunner()
+		f.Set(name, busy, mttest.Response{})
+		d := f.Drive("/dev/nst0")
+		d.MaxRetries = 3
+		if err := op(d); !errors.Is(err, mt.ErrDeviceBusy) {
+			t.Errorf("%s = %v, want ErrDeviceBusy", name, err)
+		}
+		if n := len(f.Calls()); n != 1 {
+			t.Errorf("%s: ran mt %d times, want 1", name, n)
+		}
+	}
+}
+
+func TestPositionUnknown(t *testing.T) {
+	for _, out := range []string{
+		"File number=-1, block number=-1, partition=-1.\n",
+		"File number=-1, block number=-1\n",
+	} {
+		f := mttest.NewFakeRunner()
+		f.Set("status", mttest.Response{Stdout: out})
+		file, block, part, err := f.Drive("/dev/nst0").Position()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file != -1 || block != -1 || part != -1 {
+			t.Errorf("Position(%q) = %d, %d, %d, want -1, -1, -1", out, file, block, part)
+		}
+	}
+}
+
+func TestPositionPartition(t *testing.T) {
+	for _, out := range []string{
+		"File number=3, block number=7, partition=1.\n",
+		"File number=3, block number=7, Partition = 1.\n",
+		"File number=3, block number=7, PARTITION=1.\n",
+		"File number=3, block number=7, partition =1.\n",
+		"Tape drive:\nFile number=3, block number=7, partition= 1.\n",
+	} {
+		f := mttest.NewFakeRunner()
+		f.Set("status", mttest.Response{Stdout: out})
+		file, block, part, err := f.Drive("/dev/nst0").Position()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file != 3 || block != 7 || part != 1 {
+			t.Errorf("Position(%q) = %d, %d, %d, want 3, 7, 1", out, file, block, part)
+		}
+	}
+}
+
+// twoFileTape sets f up as a tape holding two files, each ended by a
+// filemark, with EOD only reported after a space fails on blank tape.
+func twoFileTape(f *mttest.FakeRunner) {
+	f.Set("status",
+		mttest.Response{Stdout: "File number=0, block number=0, partition=0.\nGeneral status bits on (41010000):\n BOT ONLINE IM_REP_EN\n"},
+		mttest.Response{Stdout: "File number=1, block number=0, partition=0.\nGeneral status bits on (81010000):\n EOF ONLINE IM_REP_EN\n"},
+		mttest.Response{Stdout: "File number=2, block number=0, partition=0.\nGeneral status bits on (81010000):\n EOF ONLINE IM_REP_EN\n"},
+		mttest.Response{Stdout: "File number=2, block number=0, partition=0.\nGeneral status bits on (09010000):\n EOD ONLINE IM_REP_EN\n"},
+	)
+	f.Set("fsf",
+		mttest.Response{},
+		mttest.Response{},
+		mttest.Response{Stderr: "/dev/nst0: Input/output error\n", ExitCode: 2},
+	)
+}
+
+func TestEachFile(t *testing.T) {
+	f := mttest.NewFakeRunner()
+	twoFileTape(f)
+	var files []int64
+	err := f.Drive("/dev/nst0").EachFile(context.Background(), func(n int64) error {
+		files = append(files, n)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0] != 0 || files[1] != 1 {
+		t.Errorf("EachFile visited %v, want [0 1]", files)
+	}
+}
+
+func TestCountFiles(t *testing.T) {
+	f := mttest.NewFakeRunner()
+	twoFileTape(f)
+	n, err := f.Drive("/dev/nst0").CountFiles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("CountFiles = %d, want 2", n)
+	}
+}
+
+func TestMTBinaryPrecedence(t *testing.T) {
+	tests := []struct {
+		env, cmd, want string
+	}{
+		{"", "", "mt"},
+		{"/opt/bin/gmt", "", "/opt/bin/gmt"},
+		{"/opt/bin/gmt", "/usr/sbin/mt", "/usr/sbin/mt"},
+		{"", "/usr/sbin/mt", "/usr/sbin/mt"},
+	}
+	for _, tt := range tests {
+		t.Setenv(mt.MTBinaryEnv, tt.env)
+		if got := mt.NewDriveCmd("/dev/nst0", tt.cmd).Command; got != tt.want {
+			t.Errorf("MT_BINARY=%q NewDriveCmd(%q) uses %q, want %q", tt.env, tt.cmd, got, tt.want)
+		}
+		if tt.cmd == "" {
+			if got := mt.NewDrive("/dev/nst0").Command; got != tt.want {
+				t.Errorf("MT_BINARY=%q NewDrive uses %q, want %q", tt.env, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestEraseArgs(t *testing.T) {
+	tests := []struct {
+		dialect     *mt.Dialect
+		short, long string
+	}{
+		{mt.LinuxDialect, "0", "1"},
+		{mt.FreeBSDDialect, "0", "1"},
+	}
+	for _, tt := range tests {
+		f := mttest.NewFakeRunner()
+		d := f.Drive("/dev/nst0")
+		d.Dialect = tt.dialect
+		if err := d.EraseShort(); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.EraseLong(); err != nil {
+			t.Fatal(err)
+		}
+		calls := f.Calls()
+		if got := strings.Join(calls[0], " "); got != "-f /dev/nst0 erase "+tt.short {
+			t.Errorf("%s EraseShort ran %q", tt.dialect.Name, got)
+		}
+		if got := strings.Join(calls[1], " "); got != "-f /dev/nst0 erase "+tt.long {
+			t.Errorf("%s EraseLong ran %q", tt.dialect.Name, got)
+		}
+	}
+}
+
+func TestLinuxDialectOps(t *testing.T) {
+	for _, op := range []string{"eof", "seod", "rewoffl", "options", "stsetoptions", "densities"} {
+		if !mt.LinuxDialect.Supports(op) {
+			t.Errorf("LinuxDialect does not support %q", op)
+		}
+	}
+	for _, op := range []string{"rblim", "reset"} {
+		if mt.LinuxDialect.Supports(op) {
+			t.Errorf("LinuxDialect supports %q, which mt-st does not have", op)
+		}
+	}
+}
+
+func TestEraseArgsUnsupported(t *testing.T) {
+	f := mttest.NewFakeRunner()
+	d := f.Drive("/dev/nst0")
+	d.Dialect = mt.GNUDialect
+	if err := d.EraseLong(); !errors.Is(err, mt.ErrUnsupportedOperation) {
+		t.Errorf("EraseLong = %v, want ErrUnsupportedOperation", err)
+	}
+	if n := len(f.Calls()); n != 0 {
+		t.Errorf("ran mt %d times, want 0", n)
+	}
+}
+
+// partitionDialect returns LinuxDialect with a ParseStatus reporting
+// counts as the number of partitions of successive statuses, the last one
+// repeating, as a custom Dialect for an mt printing the count could.
+// mt-st itself never prints it.
+func partitionDialect(counts ...int64) *mt.Dialect {
+	dl := *mt.LinuxDialect
+	dl.ParseStatus = func(out string) (*mt.StatusInfo, error) {
+		info, err := mt.ParseStatus(out)
+		if err == nil {
+			info.Partitions = counts[0]
+			if len(counts) > 1 {
+				counts = counts[1:]
+			}
+		}
+		return info, err
+	}
+	return &dl
+}
+
+func TestPartitionCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect *mt.Dialect
+		want    int
+	}{
+		{"partitioned", partitionDialect(2), 2},
+		{"unpartitioned", partitionDialect(1), 1},
+	}
+	for _, tt := range tests {
+		f := mttest.NewFakeRunner()
+		f.Set("status", mttest.Response{Stdout: mttest.StatusOnline})
+		d := f.Drive("/dev/nst0")
+		d.Dialect = tt.dialect
+		got, err := d.PartitionCount()
+		if err != nil {
+			t.Errorf("%s: PartitionCount error %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: PartitionCount = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// mt-st does not print the count
+	f := mttest.NewFakeRunner()
+	f.Set("status", mttest.Response{Stdout: "File number=0, block number=0, partition=1.\n"})
+	if _, err := f.Drive("/dev/nst0").PartitionCount(); !errors.Is(err, mt.ErrUnsupportedOperation) {
+		t.Errorf("mt-st PartitionCount error %v, want ErrUnsupportedOperation", err)
+	}
+}
+
+// positionCalls returns the seek and partseek calls recorded by f.
+func positionCalls(f *mttest.FakeRunner) []string {
+	var calls []string
+	for _, c := range f.Calls() {
+		if op := c[2]; op == "seek" || op == "partseek" {
+			calls = append(calls, strings.Join(c[2:], " "))
+		}
+	}
+	return calls
+}
+
+func TestRestorePosition(t *testing.T) {
+	tests := []struct {
+		name string
+		now  string
+		want string
+	}{
+		{"partition changed", "File number=0, block number=7, partition=0.\n", "partseek 42 1"},
+		{"same partition", "File number=0, block number=7, partition=1.\n", "seek 42"},
+	}
+	for _, tt := range tests {
+		f := mttest.NewFakeRunner()
+		f.Set("tell", mttest.Response{Stdout: "At block 42.\n"})
+		f.Set("status",
+			mttest.Response{Stdout: "File number=0, block number=42, partition=1.\n"},
+			mttest.Response{Stdout: tt.now})
+		d := f.Drive("/dev/nst0")
+		p, err := d.SavePosition()
+		if err != nil {
+			t.Fatalf("%s: SavePosition: %v", tt.name, err)
+		}
+		if p.Block() != 42 || p.Partition() != 1 {
+			t.Fatalf("%s: saved block %d partition %d, want 42 1", tt.name, p.Block(), p.Partition())
+		}
+		if err := d.RestorePosition(p); err != nil {
+			t.Fatalf("%s: RestorePosition: %v", tt.name, err)
+		}
+		if got := positionCalls(f); len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%s: calls %q, want %q", tt.name, got, tt.want)
+		}
+		status := 0
+		for _, c := range f.Calls() {
+			if c[2] == "status" {
+				status++
+			}
+		}
+		if status != 2 {
+			t.Errorf("%s: ran status %d times, want once each to save and restore", tt.name, status)
+		}
+	}
+}
+
+func TestBackwardFileMarksArgs(t *testing.T) {
+	tests := []struct {
+		dialect *mt.Dialect
+		want    string
+	}{
+		{mt.LinuxDialect, "bsfm 2"},
+		{mt.FreeBSDDialect, "nbsf 2"},
+	}
+	for _, tt := range tests {
+		f := mttest.NewFakeRunner()
+		d := f.Drive("/dev/nst0")
+		d.Dialect = tt.dialect
+		if err := d.BackwardFileMarks(2); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(f.Calls()[0][2:], " "); got != tt.want {
+			t.Errorf("%s BackwardFileMarks ran %q, want %q", tt.dialect.Name, got, tt.want)
+		}
+	}
+	d := mttest.NewFakeRunner().Drive("/dev/nst0")
+	d.Dialect = mt.GNUDialect
+	if err := d.BackwardFileMarks(2); !errors.Is(err, mt.ErrUnsupportedOperation) {
+		t.Errorf("gnu BackwardFileMarks error %v, want ErrUnsupportedOperation", err)
+	}
+}
+
+func TestStatusJSONRoundTrip(t *testing.T) {
+	info, err := mt.ParseStatus(mttest.StatusCleaning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got mt.StatusInfo
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal %s: %v", b, err)
+	}
+	if !reflect.DeepEqual(&got, info) {
+		t.Errorf("round trip of %s gave %+v, want %+v", b, got, *info)
+	}
+	if !got.Bits.CleanReq || !got.Bits.BOT || !got.Bits.Online {
+		t.Errorf("round trip flags %v, want BOT ONLINE CLN", got.Bits.Flags)
+	}
+
+	if err := json.Unmarshal([]byte(`{"flags":["BOT","NOPE"]}`), &got); err == nil {
+		t.Error("Unmarshal accepted an unknown flag")
+	}
+	if err := json.Unmarshal([]byte(`{"flags":["EOD"]}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.FileNumber != -1 || got.DensityCode != -1 || !got.Bits.EOD {
+		t.Errorf("partial status decoded as %+v", got)
+	}
+}
+
+func TestSeekPartitionBounds(t *testing.T) {
+	f := mttest.NewFakeRunner()
+	f.Set("status", mttest.Response{Stdout: mttest.StatusOnline})
+	d := f.Drive("/dev/nst0")
+	d.Dialect = partitionDialect(2)
+	if err := d.SeekPartition(5, 2); err == nil {
+		t.Error("SeekPartition to partition 2 of 2 succeeded")
+	}
+	if got := positionCalls(f); len(got) != 0 {
+		t.Errorf("out of range seek ran %q", got)
+	}
+	if err := d.SeekPartition(5, 1); err != nil {
+		t.Errorf("SeekPartition to partition 1 of 2: %v", err)
+	}
+
+	// without a count, as with mt-st, or if status fails, the seek is
+	// still attempted
+	for _, status := range []mttest.Response{
+		{Stdout: mttest.StatusOnline},
+		{Stderr: "/dev/nst0: Input/output error\n", ExitCode: 2},
+	} {
+		f := mttest.NewFakeRunner()
+		f.Set("status", status)
+		f.Drive("/dev/nst0").SeekPartition(5, 2)
+		if got := positionCalls(f); len(got) != 1 || got[0] != "partseek 5 2" {
+			t.Errorf("status %q: calls %q, want partseek 5 2", status.Stdout+status.Stderr, got)
+		}
+	}
+}
+
+func TestMakePartitionVerifiedUnreported(t *testing.T) {
+	f := mttest.NewFakeRunner()
+	f.Set("status", mttest.Response{Stdout: mttest.StatusOnline})
+	err := f.Drive("/dev/nst0").MakePartitionVerified(100)
+	if !errors.Is(err, mt.ErrUnsupportedOperation) {
+		t.Errorf("MakePartitionVerified error %v, want ErrUnsupportedOperation", err)
+	}
+	for _, c := range f.Calls() {
+		if c[2] != "status" {
+			t.Errorf("MakePartitionVerified ran %q without a partition count", c)
+		}
+	}
+
+	f = mttest.NewFakeRunner()
+	f.Set("status", mttest.Response{Stdout: mttest.StatusOnline})
+	d := f.Drive("/dev/nst0")
+	d.Dialect = partitionDialect(1, 2)
+	if err := d.MakePartitionVerified(100); err != nil {
+		t.Errorf("MakePartitionVerified: %v", err)
+	}
+}
+
+func TestZeroCountSkipped(t *testing.T) {
+	f := mttest.NewFakeRunner()
+	d := f.Drive("/dev/nst0")
+	if err := d.ForwardFileMarks(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.BackwardFileMarks(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Exec(mt.OpForwardFiles(0), mt.OpBackwardRecords(0)); err != nil {
+		t.Fatal(err)
+	}
+	if calls := f.Calls(); len(calls) != 0 {
+		t.Fatalf("zero counts ran %q", calls)
+	}
+	if _, err := d.Exec(mt.OpRewind(), mt.OpForwardFiles(0), mt.OpWriteEOFMarks(0)); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(f.Calls()[0][2:], " "); got != "rewind weof 0" {
+		t.Errorf("Exec ran %q, want rewind weof 0", got)
+	}
+}
+
+func TestEachFileUnknownFileNumber(t *testing.T) {
+	for name, d := range map[string]*mt.Drive{
+		"dry run": mt.NewDriveOpts("/dev/nst0", mt.WithDryRun()),
+		"no file number": func() *mt.Drive {
+			f := mttest.NewFakeRunner()
+			f.Set("status", mttest.Response{Stdout: "General status bits on (1010000):\n ONLINE IM_REP_EN\n"})
+			return f.Drive("/dev/nst0")
+		}(),
+	} {
+		calls := 0
+		err := d.EachFile(context.Background(), func(int64) error {
+			calls++
+			return nil
+		})
+		if err == nil || calls != 0 {
+			t.Errorf("%s: EachFile = %v after %d calls, want an error and none", name, err, calls)
+		}
+		if _, err := d.CountFiles(context.Background()); err == nil {
+			t.Errorf("%s: CountFiles succeeded", name)
+		}
+	}
+}
+
+func TestDryRunReaders(t *testing.T) {
+	d := mt.NewDriveOpts("/dev/nst0", mt.WithDryRun())
+	if err := d.Rewind(); err != nil {
+		t.Errorf("Rewind: %v", err)
+	}
+	if _, err := d.Raw("status"); err != nil {
+		t.Errorf("Raw: %v", err)
+	}
+	ctx := context.Background()
+	for name, read := range map[string]func() error{
+		"Status":             func() error { _, err := d.Status(); return err },
+		"StatusInfo":         func() error { _, err := d.StatusInfo(); return err },
+		"TellBlock":          func() error { _, err := d.TellBlock(); return err },
+		"BlockSize":          func() error { _, err := d.BlockSize(); return err },
+		"Density":            func() error { _, _, err := d.Density(); return err },
+		"PartitionCount":     func() error { _, err := d.PartitionCount(); return err },
+		"SupportedDensities": func() error { _, err := d.SupportedDensities(); return err },
+		"WaitReady":          func() error { return d.WaitReady(ctx, time.Millisecond) },
+		"WaitOnline":         func() error { return d.WaitOnline(ctx, time.Millisecond) },
+		"LoadAndWait":        func() error { return d.LoadAndWait(ctx) },
+		"Batch":              func() error { _, err := d.Batch().Rewind().Status().Run(); return err },
+	} {
+		if err := read(); !errors.Is(err, mt.ErrDryRun) {
+			t.Errorf("%s = %v, want ErrDryRun", name, err)
+		}
+	}
+}
+
+func TestOptionsMaskArgs(t *testing.T) {
+	f := mttest.NewFakeRunner()
+	d := f.Drive("/dev/nst0")
+	if err := d.StSetOptionsMask(mt.MTSTTwoFM | mt.MTSTSCSI2Logical); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.StClearOptionsMask(mt.MTSTBufferWrites); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.StSetOptionsMask(0x10000000); err == nil {
+		t.Error("StSetOptionsMask accepted an operation bit")
+	}
+	want := []string{"stsetoptions 2064", "stclearoptions 1"}
+	calls := f.Calls()
+	if len(calls) != len(want) {
+		t.Fatalf("ran %q, want %q", calls, want)
+	}
+	for i, c := range calls {
+		if got := strings.Join(c[2:], " "); got != want[i] {
+			t.Errorf("call %d ran %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestSeekLogicalArgs(t *testing.T) {
+	f := mttest.NewFakeRunner()
+	if err := f.Drive("/dev/nst0").SeekLogical(10, true); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"stsetoptions scsi2logical", "seek 10"}
+	calls := f.Calls()
+	if len(calls) != len(want) {
+		t.Fatalf("ran %q, want %q", calls, want)
+	}
+	for i, c := range calls {
+		if got := strings.Join(c[2:], " "); got != want[i] {
+			t.Errorf("call %d ran %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestWriteFileMarksEnsured(t *testing.T) {
+	f := mttest.NewFakeRunner()
+	f.Set("status",
+		mttest.Response{Stdout: "File number=1, block number=0, partition=0.\n"},
+		mttest.Response{Stdout: "File number=3, block number=0, partition=0.\n"})
+	f.Set("stshowopt", mttest.Response{Stderr: "mt: /dev/nst0: Input/output error\n", ExitCode: 2})
+	if err := f.Drive("/dev/nst0").WriteFileMarksEnsured(2); err != nil {
+		t.Fatalf("WriteFileMarksEnsured: %v", err)
+	}
+	f.Set("status", mttest.Response{Stdout: "File number=3, block number=0, partition=0.\n"})
+	if err := f.Drive("/dev/nst0").WriteFileMarksEnsured(1); !errors.Is(err, mt.ErrVerifyFailed) {
+		t.Errorf("WriteFileMarksEnsured without an advance = %v, want ErrVerifyFailed", err)
+	}
+}
+
+func TestSeekToAppend(t *testing.T) {
+	const (
+		eod   = "File number=3, block number=-1, partition=0.\nGeneral status bits on (08010000):\n EOD ONLINE\n"
+		noEOD = "File number=3, block number=0, partition=0.\nGeneral status bits on (01010000):\n ONLINE\n"
+		moved = "File number=4, block number=0, partition=0.\nGeneral status bits on (81010000):\n EOF ONLINE\n"
+	)
+	fsfFails := mttest.Response{Stderr: "mt: /dev/nst0: Input/output error\n", ExitCode: 2}
+	tests := []struct {
+		name   string
+		status []mttest.Response
+		fsf    mttest.Response
+		ok     bool
+	}{
+		{"eod bit", []mttest.Response{{Stdout: eod}}, mttest.Response{}, true},
+		{"fsf fails in place", []mttest.Response{{Stdout: noEOD}}, fsfFails, true},
+		{"fsf succeeds", []mttest.Response{{Stdout: noEOD}, {Stdout: moved}}, mttest.Response{}, false},
+		{"fsf fails and moves", []mttest.Response{{Stdout: noEOD}, {Stdout: moved}}, fsfFails, false},
+	}
+	for _, tt := range tests {
+		f := mttest.NewFakeRunner()
+		f.Set("status", tt.status...)
+		f.Set("fsf", tt.fsf)
+		err := f.Drive("/dev/nst0").SeekToAppend()
+		if tt.ok && err != nil {
+			t.Errorf("%s: SeekToAppend = %v", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, mt.ErrVerifyFailed) {
+			t.Errorf("%s: SeekToAppend = %v, want ErrVerifyFailed", tt.name, err)
+		}
+	}
+}
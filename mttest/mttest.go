@@ -0,0 +1,223 @@
+/*
+Package mttest provides fake mt commands for testing code that uses
+package mt without a tape drive.
+
+FakeMT writes a small shell script standing in for the mt executable, for
+tests that exercise the whole exec path. FakeRunner is an mt.Runner that
+answers in process. Both record the arguments of each call and reply with
+canned Responses keyed by operation keyword.
+*/
+package mttest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/benmcclelland/mt"
+	"github.com/pkg/errors"
+)
+
+// Response is the canned reply to an operation.
+type Response struct {
+	// Stdout is written to stdout
+	Stdout string
+	// Stderr is written to stderr
+	Stderr string
+	// ExitCode is the exit status
+	ExitCode int
+}
+
+// Sample mt-st status output.
+const (
+	// StatusOnline is an LTO-5 drive online at the beginning of tape
+	StatusOnline = `SCSI 2 tape drive:
+File number=0, block number=0, partition=0.
+Tape block size 0 bytes. Density code 0x58 (LTO-5).
+Soft error count since last status=0
+General status bits on (41010000):
+ BOT ONLINE IM_REP_EN
+`
+	// StatusCleaning is an LTO-5 drive online at the beginning of tape
+	// requesting cleaning
+	StatusCleaning = `SCSI 2 tape drive:
+File number=0, block number=0, partition=0.
+Tape block size 0 bytes. Density code 0x58 (LTO-5).
+Soft error count since last status=0
+General status bits on (41018000):
+ BOT ONLINE IM_REP_EN CLN
+`
+	// StatusNoTape is a drive with no tape loaded
+	StatusNoTape = `SCSI 2 tape drive:
+File number=-1, block number=-1, partition=0.
+Tape block size 0 bytes. Density code 0x0 (default).
+Soft error count since last status=0
+General status bits on (50000):
+ DR_OPEN IM_REP_EN
+`
+)
+
+// operation returns the operation keyword in an mt argument list,
+// skipping the -f device option.
+func operation(args []string) string {
+	if len(args) >= 2 && args[0] == "-f" {
+		args = args[2:]
+	}
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+const script = `#!/bin/sh
+dir='%s'
+echo "$@" >> "$dir/calls"
+op="$1"
+if [ "$op" = "-f" ]; then
+	op="$3"
+fi
+[ -f "$dir/$op.out" ] && cat "$dir/$op.out"
+[ -f "$dir/$op.err" ] && cat "$dir/$op.err" >&2
+code=0
+[ -f "$dir/$op.code" ] && code=$(cat "$dir/$op.code")
+exit $code
+`
+
+// FakeMT is a fake mt executable in a temporary directory. Operations
+// without a Response succeed with no output.
+type FakeMT struct {
+	// Path is the path of the fake mt executable
+	Path string
+	dir  string
+}
+
+// NewFakeMT writes a fake mt executable to a new temporary directory.
+// Close removes it.
+func NewFakeMT() (*FakeMT, error) {
+	dir, err := os.MkdirTemp("", "mttest")
+	if err != nil {
+		return nil, errors.Wrap(err, "fake mt")
+	}
+	path := filepath.Join(dir, "mt")
+	err = os.WriteFile(path, []byte(fmt.Sprintf(script, dir)), 0755)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, errors.Wrap(err, "fake mt")
+	}
+	return &FakeMT{Path: path, dir: dir}, nil
+}
+
+// Set sets the Response to operation op, e.g. "status".
+func (f *FakeMT) Set(op string, r Response) error {
+	base := filepath.Join(f.dir, op)
+	if err := os.WriteFile(base+".out", []byte(r.Stdout), 0644); err != nil {
+		return errors.Wrap(err, "fake mt")
+	}
+	if err := os.WriteFile(base+".err", []byte(r.Stderr), 0644); err != nil {
+		return errors.Wrap(err, "fake mt")
+	}
+	code := []byte(strconv.Itoa(r.ExitCode))
+	if err := os.WriteFile(base+".code", code, 0644); err != nil {
+		return errors.Wrap(err, "fake mt")
+	}
+	return nil
+}
+
+// Calls returns the argument list of each call made so far, split on
+// spaces.
+func (f *FakeMT) Calls() ([][]string, error) {
+	b, err := os.ReadFile(filepath.Join(f.dir, "calls"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "fake mt")
+	}
+	var calls [][]string
+	for _, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+		calls = append(calls, strings.Fields(line))
+	}
+	return calls, nil
+}
+
+// Drive returns an mt.Drive for device using the fake mt executable.
+func (f *FakeMT) Drive(device string) *mt.Drive {
+	return mt.NewDriveCmd(device, f.Path)
+}
+
+// Close removes the fake mt executable and its directory.
+func (f *FakeMT) Close() error {
+	return os.RemoveAll(f.dir)
+}
+
+// FakeRunner is an mt.Runner replying with canned Responses without
+// starting a process. Operations without a Response succeed with no
+// output. It is safe for concurrent use.
+type FakeRunner struct {
+	mu        sync.Mutex
+	responses map[string][]Response
+	calls     [][]string
+}
+
+// NewFakeRunner returns a FakeRunner with no Responses.
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{responses: make(map[string][]Response)}
+}
+
+// Set sets the Response to operation op, e.g. "status". If several
+// Responses are given they are used for successive calls, the last one
+// repeating, e.g. to fail twice then succeed.
+func (f *FakeRunner) Set(op string, r ...Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses[op] = r
+}
+
+// Run records args and returns the Response for their operation. A
+// non-zero ExitCode is returned as an *mt.CommandError.
+func (f *FakeRunner) Run(name string, args []string) ([]byte, error) {
+	out, _, err := f.RunStderr(name, args)
+	return out, err
+}
+
+// RunStderr is Run also returning the Response Stderr, making FakeRunner
+// an mt.StderrRunner.
+func (f *FakeRunner) RunStderr(name string, args []string) ([]byte, []byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, append([]string(nil), args...))
+	op := operation(args)
+	rs := f.responses[op]
+	if len(rs) == 0 {
+		return []byte{}, []byte{}, nil
+	}
+	r := rs[0]
+	if len(rs) > 1 {
+		f.responses[op] = rs[1:]
+	}
+	if r.ExitCode != 0 {
+		return []byte{}, []byte(r.Stderr), &mt.CommandError{
+			ExitCode: r.ExitCode,
+			Stderr:   strings.TrimSuffix(r.Stderr, "\n"),
+			Args:     args,
+		}
+	}
+	return []byte(r.Stdout), []byte(r.Stderr), nil
+}
+
+// Calls returns the argument list of each call made so far.
+func (f *FakeRunner) Calls() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string(nil), f.calls...)
+}
+
+// Drive returns an mt.Drive for device using the FakeRunner.
+func (f *FakeRunner) Drive(device string) *mt.Drive {
+	d := mt.NewDrive(device)
+	d.Runner = f
+	return d
+}
@@ -0,0 +1,75 @@
+package mttest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/benmcclelland/mt"
+	"github.com/pkg/errors"
+)
+
+func TestFakeMT(t *testing.T) {
+	f, err := NewFakeMT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Set("status", Response{Stdout: StatusOnline}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("fsf", Response{Stderr: "/dev/nst0: Input/output error\n", ExitCode: 2}); err != nil {
+		t.Fatal(err)
+	}
+	d := f.Drive("/dev/nst0")
+	info, err := d.StatusInfo()
+	if err != nil {
+		t.Fatalf("StatusInfo: %v", err)
+	}
+	if info.FileNumber != 0 || info.BlockNumber != 0 {
+		t.Errorf("StatusInfo at file %d block %d, want 0 0", info.FileNumber, info.BlockNumber)
+	}
+	var ce *mt.CommandError
+	if err := d.ForwardFiles(3); !errors.As(err, &ce) || ce.ExitCode != 2 {
+		t.Errorf("ForwardFiles error %v, want exit status 2", err)
+	}
+	calls, err := f.Calls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"-f", "/dev/nst0", "status"},
+		{"-f", "/dev/nst0", "fsf", "3"},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls %q, want %q", calls, want)
+	}
+}
+
+func TestFakeRunner(t *testing.T) {
+	f := NewFakeRunner()
+	f.Set("tell",
+		Response{Stdout: "At block 7.\n"},
+		Response{Stdout: "At block 9.\n"})
+	d := f.Drive("/dev/nst0")
+	for _, want := range []int64{7, 9, 9} {
+		got, err := d.TellBlock()
+		if err != nil {
+			t.Fatalf("TellBlock: %v", err)
+		}
+		if got != want {
+			t.Errorf("TellBlock = %d, want %d", got, want)
+		}
+	}
+	if err := d.SeekTape(42); err != nil {
+		t.Fatalf("SeekTape: %v", err)
+	}
+	want := [][]string{
+		{"-f", "/dev/nst0", "tell"},
+		{"-f", "/dev/nst0", "tell"},
+		{"-f", "/dev/nst0", "tell"},
+		{"-f", "/dev/nst0", "seek", "42"},
+	}
+	if calls := f.Calls(); !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls %q, want %q", calls, want)
+	}
+}
@@ -0,0 +1,173 @@
+package mt
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Op is a single mt operation for use with Exec.
+type Op struct {
+	// Name is the mt operation keyword, e.g. fsf
+	Name string
+	// Args are the operation arguments, e.g. the count
+	Args []string
+	// err records an invalid count, returned by Exec
+	err error
+	// skip is set for spacing operations with a count of 0
+	skip bool
+}
+
+// NewOp returns an Op for an mt-st operation keyword and arguments. Exec
+// translates name to the keyword of the Drive's dialect, so it must be
+// an operation the dialect supports.
+func NewOp(name string, args ...string) Op {
+	return Op{Name: name, Args: args}
+}
+
+// countOp returns an Op taking a count. If noop is set, a count of 0 is
+// skipped by Exec, as the Drive spacing methods skip it.
+func countOp(name string, n int64, noop bool) Op {
+	if err := checkCount(n); err != nil {
+		return Op{Name: name, err: errors.Wrap(err, name)}
+	}
+	return Op{Name: name, Args: []string{strconv.FormatInt(n, 10)}, skip: noop && n == 0}
+}
+
+// OpForwardFiles returns an Op to forward space n files.
+func OpForwardFiles(n int64) Op { return countOp("fsf", n, true) }
+
+// OpBackwardFiles returns an Op to backward space n files.
+func OpBackwardFiles(n int64) Op { return countOp("bsf", n, true) }
+
+// OpPositionToFile returns an Op to position to the beginning of the nth file.
+func OpPositionToFile(n int64) Op { return countOp("asf", n, false) }
+
+// OpForwardRecords returns an Op to forward space n records.
+func OpForwardRecords(n int64) Op { return countOp("fsr", n, true) }
+
+// OpBackwardRecords returns an Op to backward space n records.
+func OpBackwardRecords(n int64) Op { return countOp("bsr", n, true) }
+
+// OpSeekTape returns an Op to seek to the nth block.
+func OpSeekTape(n int64) Op { return countOp("seek", n, false) }
+
+// OpSetPartition returns an Op to switch to the nth partition.
+func OpSetPartition(n int64) Op { return countOp("setpartition", n, false) }
+
+// OpWriteEOFMarks returns an Op to write n EOF marks.
+func OpWriteEOFMarks(n int64) Op { return countOp("weof", n, false) }
+
+// OpPositionEOD returns an Op to position to end of valid data.
+func OpPositionEOD() Op { return NewOp("eod") }
+
+// OpRewind returns an Op to rewind the tape.
+func OpRewind() Op { return NewOp("rewind") }
+
+// OpStatus returns an Op to print the drive status.
+func OpStatus() Op { return NewOp("status") }
+
+// OpTell returns an Op to print the current block on tape.
+func OpTell() Op { return NewOp("tell") }
+
+// Exec runs all ops in a single mt invocation, e.g.
+//
+//	mt -f /dev/nst0 rewind fsf 2 status
+//
+// which saves starting a process per operation. mt stops at the first
+// operation that fails, and the returned error does not identify which one
+// it was. The output of all ops is returned combined. Spacing ops with a
+// count of 0 are left out, and if no ops remain mt is not run.
+func (d *Drive) Exec(ops ...Op) ([]byte, error) {
+	if len(ops) == 0 {
+		return nil, errors.New("exec: no operations")
+	}
+	var args []string
+	for _, op := range ops {
+		if op.err != nil {
+			return nil, errors.Wrap(op.err, "exec")
+		}
+		if op.skip {
+			continue
+		}
+		kw, err := d.keyword(op.Name)
+		if err != nil {
+			return nil, errors.Wrap(err, "exec")
+		}
+		args = append(args, kw)
+		args = append(args, op.Args...)
+	}
+	if len(args) == 0 {
+		return []byte{}, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result, err := d.mtCmd(args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "exec")
+	}
+	return result, nil
+}
+
+// OpCost is the estimated duration of an operation, Fixed plus PerCount
+// for each unit of its count argument.
+type OpCost struct {
+	// Fixed is the cost of the operation regardless of its count
+	Fixed time.Duration
+	// PerCount is the cost per unit of the count argument, e.g. per file
+	// spaced over by fsf
+	PerCount time.Duration
+}
+
+// OpCosts are the rough operation costs used by EstimateDuration, keyed
+// by mt-st operation keyword. They assume an LTO class drive and a worst
+// case position on the tape, and may be replaced or extended to match
+// other drives.
+var OpCosts = map[string]OpCost{
+	"status":       {Fixed: 100 * time.Millisecond},
+	"tell":         {Fixed: 100 * time.Millisecond},
+	"rewind":       {Fixed: 90 * time.Second},
+	"eod":          {Fixed: 90 * time.Second},
+	"seek":         {Fixed: 60 * time.Second},
+	"partseek":     {Fixed: 60 * time.Second},
+	"setpartition": {Fixed: 60 * time.Second},
+	"asf":          {Fixed: 90 * time.Second, PerCount: 5 * time.Second},
+	"fsf":          {Fixed: time.Second, PerCount: 5 * time.Second},
+	"bsf":          {Fixed: time.Second, PerCount: 5 * time.Second},
+	"fsr":          {Fixed: time.Second, PerCount: 10 * time.Millisecond},
+	"bsr":          {Fixed: time.Second, PerCount: 10 * time.Millisecond},
+	"weof":         {Fixed: time.Second, PerCount: time.Second},
+	"load":         {Fixed: 30 * time.Second},
+	"eject":        {Fixed: 2 * time.Minute},
+	"offline":      {Fixed: 2 * time.Minute},
+	"retension":    {Fixed: 5 * time.Minute},
+	"erase":        {Fixed: 3 * time.Hour},
+	"mkpartition":  {Fixed: 5 * time.Minute},
+}
+
+// DefaultOpCost is the cost of operations missing from OpCosts.
+var DefaultOpCost = OpCost{Fixed: time.Second}
+
+// EstimateDuration returns a rough estimate of how long ops take, from
+// OpCosts, for use in picking timeouts. It is a heuristic: actual times
+// depend on the drive, the tape and where on it the operations start.
+func EstimateDuration(ops []Op) time.Duration {
+	var total time.Duration
+	for _, op := range ops {
+		if op.skip {
+			continue
+		}
+		cost, ok := OpCosts[op.Name]
+		if !ok {
+			cost = DefaultOpCost
+		}
+		total += cost.Fixed
+		if cost.PerCount != 0 && len(op.Args) > 0 {
+			if n, err := strconv.ParseInt(op.Args[0], 10, 64); err == nil && n > 0 {
+				total += time.Duration(n) * cost.PerCount
+			}
+		}
+	}
+	return total
+}
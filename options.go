@@ -0,0 +1,128 @@
+package mt
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Driver option bits from linux/mtio.h for StSetOptionsMask and
+// StClearOptionsMask.
+const (
+	MTSTBufferWrites  uint32 = 0x1
+	MTSTAsyncWrites   uint32 = 0x2
+	MTSTReadAhead     uint32 = 0x4
+	MTSTDebugging     uint32 = 0x8
+	MTSTTwoFM         uint32 = 0x10
+	MTSTFastMTEOM     uint32 = 0x20
+	MTSTAutoLock      uint32 = 0x40
+	MTSTDefWrites     uint32 = 0x80
+	MTSTCanBSR        uint32 = 0x100
+	MTSTNoBlkLims     uint32 = 0x200
+	MTSTCanPartitions uint32 = 0x400
+	MTSTSCSI2Logical  uint32 = 0x800
+	MTSTSysV          uint32 = 0x1000
+	MTSTNoWait        uint32 = 0x2000
+	MTSTSILI          uint32 = 0x4000
+	MTSTNoWaitEOF     uint32 = 0x8000
+)
+
+// STOption is a driver option keyword for StSetOptions and
+// StClearOptions.
+type STOption string
+
+// Driver option keywords accepted by mt-st.
+const (
+	OptionBufferWrites  STOption = "buffer-writes"
+	OptionAsyncWrites   STOption = "async-writes"
+	OptionReadAhead     STOption = "read-ahead"
+	OptionDebug         STOption = "debug"
+	OptionTwoFMs        STOption = "two-fms"
+	OptionFastEOD       STOption = "fast-eod"
+	OptionNoWait        STOption = "no-wait"
+	OptionAutoLock      STOption = "auto-lock"
+	OptionDefWrites     STOption = "def-writes"
+	OptionCanBSR        STOption = "can-bsr"
+	OptionNoBlkLimits   STOption = "no-blklimits"
+	OptionCanPartitions STOption = "can-partitions"
+	OptionSCSI2Logical  STOption = "scsi2logical"
+	OptionSILI          STOption = "sili"
+	OptionSysV          STOption = "sysv"
+)
+
+// optionMasks maps each STOption keyword to its option bit.
+var optionMasks = map[STOption]uint32{
+	OptionBufferWrites:  MTSTBufferWrites,
+	OptionAsyncWrites:   MTSTAsyncWrites,
+	OptionReadAhead:     MTSTReadAhead,
+	OptionDebug:         MTSTDebugging,
+	OptionTwoFMs:        MTSTTwoFM,
+	OptionFastEOD:       MTSTFastMTEOM,
+	OptionNoWait:        MTSTNoWait,
+	OptionAutoLock:      MTSTAutoLock,
+	OptionDefWrites:     MTSTDefWrites,
+	OptionCanBSR:        MTSTCanBSR,
+	OptionNoBlkLimits:   MTSTNoBlkLims,
+	OptionCanPartitions: MTSTCanPartitions,
+	OptionSCSI2Logical:  MTSTSCSI2Logical,
+	OptionSILI:          MTSTSILI,
+	OptionSysV:          MTSTSysV,
+}
+
+// Mask returns the option bit for the keyword o, false if o is not a
+// known keyword.
+func (o STOption) Mask() (uint32, bool) {
+	m, ok := optionMasks[o]
+	return m, ok
+}
+
+// OptionsMask returns the option bits for the keywords opts ORed together,
+// or an error naming the first unknown keyword.
+func OptionsMask(opts ...STOption) (uint32, error) {
+	var mask uint32
+	for _, o := range opts {
+		m, ok := o.Mask()
+		if !ok {
+			return 0, errors.Errorf("unknown option %q", o)
+		}
+		mask |= m
+	}
+	return mask, nil
+}
+
+func optionArgs(opts []STOption) []string {
+	args := make([]string, len(opts))
+	for i, o := range opts {
+		args[i] = string(o)
+	}
+	return args
+}
+
+// mtSTOptionsMask is the bits of the driver options word holding options,
+// the upper bits select the st ioctl operation.
+const mtSTOptionsMask uint32 = 0x0fffffff
+
+func checkMask(mask uint32) error {
+	if mask&^mtSTOptionsMask != 0 {
+		return errors.Errorf("invalid option mask %#x", mask)
+	}
+	return nil
+}
+
+// StSetOptionsMask (SCSI tapes) set the driver option bits in mask, the
+// MTST constants ORed together, leaving the others unchanged.
+func (d *Drive) StSetOptionsMask(mask uint32) error {
+	if err := checkMask(mask); err != nil {
+		return errors.Wrap(err, "stsetoptions")
+	}
+	return d.StSetSelectedOptions(STOption(strconv.FormatUint(uint64(mask), 10)))
+}
+
+// StClearOptionsMask (SCSI tapes) clear the driver option bits in mask,
+// the MTST constants ORed together.
+func (d *Drive) StClearOptionsMask(mask uint32) error {
+	if err := checkMask(mask); err != nil {
+		return errors.Wrap(err, "stclearoptions")
+	}
+	return d.StClearOptions(STOption(strconv.FormatUint(uint64(mask), 10)))
+}
@@ -0,0 +1,103 @@
+package mt
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Position is a saved tape position returned by SavePosition.
+type Position struct {
+	block     int64
+	partition int64
+}
+
+// Block returns the saved block number.
+func (p Position) Block() int64 { return p.block }
+
+// Partition returns the saved partition, -1 if the drive did not report it.
+func (p Position) Partition() int64 { return p.partition }
+
+// SavePosition (SCSI tapes) returns the current position on tape for a
+// later RestorePosition. The drive must support tell.
+func (d *Drive) SavePosition() (Position, error) {
+	block, err := d.TellBlock()
+	if err != nil {
+		return Position{}, errors.Wrap(err, "save position: drive may not support tell")
+	}
+	info, err := d.StatusInfo()
+	if err != nil {
+		return Position{}, errors.Wrap(err, "save position")
+	}
+	return Position{block: block, partition: info.Partition}, nil
+}
+
+// RestorePosition (SCSI tapes) seeks back to a position returned by
+// SavePosition. If the saved partition differs from the current one the
+// position is restored with partseek, otherwise with seek, so on single
+// partition tapes this is a plain seek.
+func (d *Drive) RestorePosition(p Position) error {
+	if p.partition >= 0 {
+		info, err := d.StatusInfo()
+		if err != nil {
+			return errors.Wrap(err, "restore position")
+		}
+		if info.Partition != p.partition {
+			// the saved partition exists, so it needs no range check
+			d.mu.Lock()
+			err := d.seekPartition(p.block, p.partition)
+			d.mu.Unlock()
+			return errors.Wrap(err, "restore position")
+		}
+	}
+	return errors.Wrap(d.SeekTape(p.block), "restore position")
+}
+
+// SelfTestPositioning (SCSI tapes) checks that seek and tell round-trip
+// on the drive, as a check before relying on SavePosition: it saves the
+// position, seeks one block back, or forward at block 0, checks tell
+// reports that block, then restores the saved position and checks tell
+// reports it again. Only positioning commands are used, nothing is
+// written. ErrVerifyFailed is returned if tell reports an unexpected
+// block. Once the first seek succeeds the saved position is restored
+// before returning, even if tell fails or ctx is done.
+func (d *Drive) SelfTestPositioning(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "self test")
+	}
+	start, err := d.SavePosition()
+	if err != nil {
+		return errors.Wrap(err, "self test")
+	}
+	target := start.block + 1
+	if start.block > 0 {
+		target = start.block - 1
+	}
+	if err := d.SeekTape(target); err != nil {
+		return errors.Wrapf(err, "self test: seek from block %d to %d", start.block, target)
+	}
+	got, tellErr := d.TellBlock()
+	ctxErr := ctx.Err()
+	if err := d.RestorePosition(start); err != nil {
+		return errors.Wrapf(err, "self test: return to block %d", start.block)
+	}
+	if tellErr != nil {
+		return errors.Wrap(tellErr, "self test")
+	}
+	if ctxErr != nil {
+		return errors.Wrap(ctxErr, "self test")
+	}
+	if got != target {
+		return errors.Wrapf(ErrVerifyFailed, "self test: tell reports block %d after seeking to %d",
+			got, target)
+	}
+	back, err := d.TellBlock()
+	if err != nil {
+		return errors.Wrap(err, "self test")
+	}
+	if back != start.block {
+		return errors.Wrapf(ErrVerifyFailed, "self test: tell reports block %d after seeking back to %d",
+			back, start.block)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package mt
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Runner runs the mt command name with args and returns its stdout.
+// A command that exits with a non-zero status should return a
+// *CommandError so that its stderr can be matched to the package errors.
+type Runner interface {
+	Run(name string, args []string) ([]byte, error)
+}
+
+// RunnerFunc is a function implementing Runner.
+type RunnerFunc func(name string, args []string) ([]byte, error)
+
+// Run calls f(name, args).
+func (f RunnerFunc) Run(name string, args []string) ([]byte, error) {
+	return f(name, args)
+}
+
+// StderrRunner is a Runner that can also return the stderr of a command
+// that succeeds. It is used by Drive.StatusVerbose; with other Runners
+// the stderr of a successful command is empty.
+type StderrRunner interface {
+	Runner
+	RunStderr(name string, args []string) (stdout, stderr []byte, err error)
+}
+
+// execRunner is the default Runner, running the command with os/exec.
+type execRunner struct {
+	// maxOutput is the limit on each of stdout and stderr, no limit if
+	// not positive
+	maxOutput int64
+	// ctx, if set, stops the command when done
+	ctx context.Context
+	// killGrace is the wait between SIGTERM and SIGKILL when stopping
+	killGrace time.Duration
+	// nice is the niceness adjustment to run the command with
+	nice int
+	// idleIO runs the command in the idle I/O scheduling class
+	idleIO bool
+}
+
+// limitBuffer collects writes up to max bytes and discards the rest, so a
+// runaway command keeps running to completion without growing it. The
+// buffer is not embedded so that io.Copy cannot bypass Write through
+// bytes.Buffer.ReadFrom.
+type limitBuffer struct {
+	buf      bytes.Buffer
+	max      int64
+	exceeded bool
+}
+
+func (b *limitBuffer) Write(p []byte) (int, error) {
+	if b.max <= 0 {
+		return b.buf.Write(p)
+	}
+	if room := b.max - int64(b.buf.Len()); int64(len(p)) > room {
+		b.exceeded = true
+		b.buf.Write(p[:room])
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// Run starts the command and collects its output.
+func (r execRunner) Run(name string, args []string) ([]byte, error) {
+	out, _, err := r.RunStderr(name, args)
+	return out, err
+}
+
+// RunStderr starts the command and collects its output and stderr.
+func (r execRunner) RunStderr(name string, args []string) ([]byte, []byte, error) {
+	stdout := limitBuffer{max: r.maxOutput}
+	stderr := limitBuffer{max: r.maxOutput}
+	cmd := r.command(name, args)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Start and Wait rather than Run to keep start failures distinct
+	if err := cmd.Start(); err != nil {
+		err = errors.Wrap(err, "mt start command")
+		return []byte{}, []byte{}, err
+	}
+	err := cmd.Wait()
+	if r.ctx != nil && r.ctx.Err() != nil {
+		err = errors.Wrap(r.ctx.Err(), "mt wait command")
+		return []byte{}, stderr.buf.Bytes(), err
+	}
+	if stdout.exceeded || stderr.exceeded {
+		err = errors.Wrapf(ErrOutputTooLarge, "mt wait command: more than %d bytes", r.maxOutput)
+		return []byte{}, []byte{}, err
+	}
+	if err != nil {
+		cmderr := strings.TrimSuffix(stderr.buf.String(), "\n")
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			err = &CommandError{
+				ExitCode: exitErr.ExitCode(),
+				Stderr:   cmderr,
+				Args:     args,
+				err:      exitErr,
+			}
+			return []byte{}, stderr.buf.Bytes(), err
+		}
+		err = errors.Wrap(err, "mt wait command")
+		err = errors.Wrap(err, cmderr)
+		return []byte{}, stderr.buf.Bytes(), err
+	}
+	return stdout.buf.Bytes(), stderr.buf.Bytes(), nil
+}
+
+// command returns the Cmd for name and args. With a ctx, the process is
+// sent SIGTERM when ctx is done and SIGKILL if it has not exited after
+// killGrace, which also bounds the wait for its output to be closed.
+func (r execRunner) command(name string, args []string) *exec.Cmd {
+	name, args = r.wrap(name, args)
+	if r.ctx == nil {
+		return exec.Command(name, args...)
+	}
+	cmd := exec.CommandContext(r.ctx, name, args...)
+	if r.killGrace > 0 {
+		cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+		cmd.WaitDelay = r.killGrace
+	}
+	return cmd
+}
+
+// wrap returns name and args prefixed with the nice and ionice commands
+// selected for the command.
+func (r execRunner) wrap(name string, args []string) (string, []string) {
+	if r.idleIO {
+		args = append([]string{"-c", "3", name}, args...)
+		name = "ionice"
+	}
+	if r.nice != 0 {
+		args = append([]string{"-n", strconv.Itoa(r.nice), name}, args...)
+		name = "nice"
+	}
+	return name, args
+}
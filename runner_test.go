@@ -0,0 +1,182 @@
+package mt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestStartFailure(t *testing.T) {
+	d := NewDrive("/dev/nst0")
+	d.Command = filepath.Join(t.TempDir(), "no-such-mt")
+	err := d.Rewind()
+	if err == nil {
+		t.Fatal("Rewind with a missing mt binary succeeded")
+	}
+	if !strings.Contains(err.Error(), "mt start command") {
+		t.Errorf("error %q does not mention mt start command", err)
+	}
+	var ce *CommandError
+	if errors.As(err, &ce) {
+		t.Errorf("start failure returned as a CommandError: %v", err)
+	}
+}
+
+// fakeMT writes a shell script with body to a temporary directory and
+// returns its path, for use as Drive.Command.
+func fakeMT(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mt")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLargeOutput(t *testing.T) {
+	const size = 1 << 20
+	// stderr is written first, filling its pipe while stdout is unread
+	d := NewDrive("/dev/nst0")
+	d.Command = fakeMT(t, `head -c 1048576 /dev/zero >&2
+head -c 1048576 /dev/zero
+`)
+	d.Timeout = 30 * time.Second
+	out, stderr, err := d.execArgsStderr([]string{"status"})
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(out) != size || len(stderr) != size {
+		t.Errorf("got %d bytes stdout and %d bytes stderr, want %d each", len(out), len(stderr), size)
+	}
+
+	d.Command = fakeMT(t, `head -c 1048576 /dev/zero
+head -c 1048576 /dev/zero | tr '\0' x >&2
+exit 2
+`)
+	_, err = d.execArgs([]string{"status"})
+	var ce *CommandError
+	if !errors.As(err, &ce) {
+		t.Fatalf("status error %v is not a CommandError", err)
+	}
+	if len(ce.Stderr) != size || strings.Trim(ce.Stderr, "x") != "" {
+		t.Errorf("CommandError has %d bytes stderr, want %d", len(ce.Stderr), size)
+	}
+}
+
+func TestCommandErrorFormat(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   string
+	}{
+		{"/dev/nst0: Input/output error", "/dev/nst0: Input/output error: mt wait command: exit status 2"},
+		{"mt: /dev/nst0: Input/output error", "/dev/nst0: Input/output error: mt wait command: exit status 2"},
+		{"mt: unknown failure", "mt: unknown failure: mt wait command: exit status 2"},
+	}
+	for _, tt := range tests {
+		d := NewDrive("/dev/nst0")
+		d.Command = fakeMT(t, "echo '"+tt.stderr+"' >&2\nexit 2\n")
+		err := d.Rewind()
+		if err == nil {
+			t.Fatalf("%q: Rewind succeeded", tt.stderr)
+		}
+		if got := err.Error(); got != "rewind: "+tt.want {
+			t.Errorf("%q: error %q, want %q", tt.stderr, got, "rewind: "+tt.want)
+		}
+	}
+}
+
+func TestDeviceLockWaitNotTimed(t *testing.T) {
+	const hold = 500 * time.Millisecond
+	device := filepath.Join(t.TempDir(), "nst0")
+	started := make(chan struct{})
+	a := NewDriveShared(device)
+	a.Runner = RunnerFunc(func(name string, args []string) ([]byte, error) {
+		close(started)
+		time.Sleep(hold)
+		return []byte{}, nil
+	})
+	done := make(chan error)
+	go func() { done <- a.Rewind() }()
+	<-started
+
+	b := NewDriveShared(device)
+	b.Command = fakeMT(t, "exit 0\n")
+	b.Timeout = hold / 2
+	if err := b.Rewind(); err != nil {
+		t.Errorf("Rewind waiting for the device lock: %v", err)
+	}
+	if dur := b.LastCommandDuration(); dur >= hold/2 {
+		t.Errorf("LastCommandDuration %v includes the device lock wait", dur)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeviceLockResolved(t *testing.T) {
+	dir := t.TempDir()
+	device := filepath.Join(dir, "nst0")
+	if err := os.WriteFile(device, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	alias := filepath.Join(dir, "tape")
+	if err := os.Symlink(device, alias); err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewDriveResolved(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewDriveResolved(alias)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.devLock == nil || a.devLock != b.devLock {
+		t.Error("resolved Drives for a device and its alias do not share a lock")
+	}
+	if c := NewDriveShared(alias); c.devLock != a.devLock {
+		t.Error("shared and resolved Drives for one device do not share a lock")
+	}
+}
+
+func TestTimeoutSIGTERM(t *testing.T) {
+	tests := []struct {
+		name    string
+		onTerm  string
+		grace   time.Duration
+		minTime time.Duration
+	}{
+		// exits on SIGTERM, so is not waited on for the grace period
+		{"trapped", "exit 143", 5 * time.Second, 0},
+		// ignores SIGTERM, so is killed once the grace period is over
+		{"ignored", ":", 300 * time.Millisecond, 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		term := filepath.Join(t.TempDir(), "term")
+		d := NewDrive("/dev/nst0")
+		d.Command = fakeMT(t, "trap 'echo TERM >> "+term+"; "+tt.onTerm+"' TERM\n"+
+			"while :; do sleep 0.05; done\n")
+		d.Timeout = 200 * time.Millisecond
+		d.KillGrace = tt.grace
+		start := time.Now()
+		err := d.Rewind()
+		elapsed := time.Since(start)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: Rewind error %v, want deadline exceeded", tt.name, err)
+		}
+		if b, _ := os.ReadFile(term); string(b) != "TERM\n" {
+			t.Errorf("%s: mt received %q before exiting, want one SIGTERM", tt.name, b)
+		}
+		if elapsed < d.Timeout+tt.minTime {
+			t.Errorf("%s: Rewind returned after %v, before the grace period", tt.name, elapsed)
+		}
+		if elapsed > d.Timeout+tt.minTime+2*time.Second {
+			t.Errorf("%s: Rewind returned after %v", tt.name, elapsed)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package mt
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// StatusInfo holds the fields parsed from mt status output.
+// Numeric fields not reported by the drive are set to -1.
+type StatusInfo struct {
+	// FileNumber is the current file number
+	FileNumber int64
+	// BlockNumber is the current block number within the file
+	BlockNumber int64
+	// Partition is the current partition as printed in status, -1 if it
+	// is printed as -1 for unknown or not printed at all
+	Partition int64
+	// Partitions is the number of partitions on the tape. Neither mt-st
+	// nor FreeBSD mt print it, so it is always -1 from ParseStatus and
+	// ParseFreeBSDStatus; a custom Dialect ParseStatus may set it
+	Partitions int64
+	// BlockSize is the tape block size in bytes, 0 for variable block mode
+	BlockSize int64
+	// DensityCode is the density code of the loaded tape
+	DensityCode int64
+	// Bits is the general status bits
+	Bits StatusBits
+	// Compression is whether drive compression is enabled, nil if the
+	// drive does not report it
+	Compression *bool
+	// Residual is the residue count of the last operation, nil if the
+	// drive does not report it
+	Residual *int64
+	// DriveType is the drive type mt reports, such as "SCSI 2" from
+	// "SCSI 2 tape drive:", or the number printed as "drive type = N" for
+	// drives mt does not know. It is empty if not reported.
+	DriveType string
+	// SoftErrors is the number of recovered errors since the last status,
+	// the "Soft error count since last status" printed for SCSI drives
+	SoftErrors int64
+}
+
+var (
+	fileNumberRe  = regexp.MustCompile(`(?i)file number\s*=\s*(-?\d+)`)
+	blockNumberRe = regexp.MustCompile(`(?i)block number\s*=\s*(-?\d+)`)
+	partitionRe   = regexp.MustCompile(`(?i)\bpartition\s*=\s*(-?\d+)`)
+	blockSizeRe   = regexp.MustCompile(`Tape block size (\d+) bytes`)
+	residualRe    = regexp.MustCompile(`residue count\s*=\s*(-?\d+)`)
+	compressionRe = regexp.MustCompile(`(?i)compression\s*[=:]?\s*(\w+)`)
+	densityRe     = regexp.MustCompile(`Density code (0x[0-9a-fA-F]+)`)
+	driveNameRe   = regexp.MustCompile(`(?m)^\s*(.+?) tape drive:`)
+	driveTypeRe   = regexp.MustCompile(`drive type\s*=\s*(\S+)`)
+	softErrorsRe  = regexp.MustCompile(`(?i)soft error count since last status\s*=\s*(\d+)`)
+)
+
+// ParseStatus parses the output of mt-st status.
+func ParseStatus(out string) (*StatusInfo, error) {
+	info := &StatusInfo{}
+	var err error
+	if info.FileNumber, err = findInt(fileNumberRe, out, 10); err != nil {
+		return nil, errors.Wrap(err, "parse file number")
+	}
+	if info.BlockNumber, err = findInt(blockNumberRe, out, 10); err != nil {
+		return nil, errors.Wrap(err, "parse block number")
+	}
+	if info.Partition, err = findInt(partitionRe, out, 10); err != nil {
+		return nil, errors.Wrap(err, "parse partition")
+	}
+	// mt-st does not print the number of partitions
+	info.Partitions = -1
+	if info.BlockSize, err = findInt(blockSizeRe, out, 10); err != nil {
+		return nil, errors.Wrap(err, "parse block size")
+	}
+	if info.DensityCode, err = findInt(densityRe, out, 0); err != nil {
+		return nil, errors.Wrap(err, "parse density code")
+	}
+	if m := compressionRe.FindStringSubmatch(out); m != nil {
+		info.Compression = parseCompression(m[1])
+	}
+	if info.Residual, err = findIntPtr(residualRe, out); err != nil {
+		return nil, errors.Wrap(err, "parse residue count")
+	}
+	if info.SoftErrors, err = findInt(softErrorsRe, out, 10); err != nil {
+		return nil, errors.Wrap(err, "parse soft error count")
+	}
+	if m := driveTypeRe.FindStringSubmatch(out); m != nil {
+		info.DriveType = m[1]
+	} else if m := driveNameRe.FindStringSubmatch(out); m != nil {
+		info.DriveType = m[1]
+	}
+	info.Bits = ParseGeneralStatusBits(out)
+	return info, nil
+}
+
+// statusJSON is the JSON shape of StatusInfo.
+type statusJSON struct {
+	FileNumber  int64    `json:"file_number"`
+	BlockNumber int64    `json:"block_number"`
+	Partition   int64    `json:"partition"`
+	Partitions  int64    `json:"partitions"`
+	BlockSize   int64    `json:"block_size"`
+	DensityCode int64    `json:"density_code"`
+	DensityName string   `json:"density_name"`
+	Flags       []string `json:"flags"`
+	Compression *bool    `json:"compression"`
+	Residual    *int64   `json:"residual"`
+	DriveType   string   `json:"drive_type"`
+	SoftErrors  int64    `json:"soft_errors"`
+}
+
+// MarshalJSON encodes the status as a JSON object with the density name
+// from Densities and the general status bits set as an array of their mt
+// names, e.g. ["BOT", "ONLINE"]. Fields not reported keep their -1, empty or
+// null value, so every key is always present.
+func (s StatusInfo) MarshalJSON() ([]byte, error) {
+	j := statusJSON{
+		FileNumber:  s.FileNumber,
+		BlockNumber: s.BlockNumber,
+		Partition:   s.Partition,
+		Partitions:  s.Partitions,
+		BlockSize:   s.BlockSize,
+		DensityCode: s.DensityCode,
+		Flags:       s.Bits.Flags.names(),
+		Compression: s.Compression,
+		Residual:    s.Residual,
+		DriveType:   s.DriveType,
+		SoftErrors:  s.SoftErrors,
+	}
+	if s.DensityCode >= 0 {
+		j.DensityName = DensityName(s.DensityCode)
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a status encoded by MarshalJSON. The general
+// status bits are set from the flag names and Raw is rebuilt from them;
+// density_name is ignored since it follows from the density code.
+// Numeric keys that are missing are -1, as if not reported.
+func (s *StatusInfo) UnmarshalJSON(b []byte) error {
+	j := statusJSON{
+		FileNumber:  -1,
+		BlockNumber: -1,
+		Partition:   -1,
+		Partitions:  -1,
+		BlockSize:   -1,
+		DensityCode: -1,
+		SoftErrors:  -1,
+	}
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	var f Flags
+	for _, name := range j.Flags {
+		known := false
+		for _, fn := range flagNames {
+			if name == fn.name {
+				f |= Flags(fn.flag)
+				known = true
+			}
+		}
+		if !known {
+			return errors.Errorf("status: unknown flag %q", name)
+		}
+	}
+	*s = StatusInfo{
+		FileNumber:  j.FileNumber,
+		BlockNumber: j.BlockNumber,
+		Partition:   j.Partition,
+		Partitions:  j.Partitions,
+		BlockSize:   j.BlockSize,
+		DensityCode: j.DensityCode,
+		Bits:        newStatusBits(uint32(f), f),
+		Compression: j.Compression,
+		Residual:    j.Residual,
+		DriveType:   j.DriveType,
+		SoftErrors:  j.SoftErrors,
+	}
+	return nil
+}
+
+// findInt returns the first submatch of re in out parsed as an integer
+// in base, or -1 if re does not match.
+func findInt(re *regexp.Regexp, out string, base int) (int64, error) {
+	m := re.FindStringSubmatch(out)
+	if m == nil {
+		return -1, nil
+	}
+	return strconv.ParseInt(m[1], base, 64)
+}
+
+// parseCompression returns the compression state for a status value,
+// nil if it does not indicate a state. Values other than the known
+// disabled ones name a compression algorithm and so mean enabled.
+func parseCompression(v string) *bool {
+	var on bool
+	switch strings.ToLower(v) {
+	case "unsupported", "unknown":
+		return nil
+	case "off", "disabled", "none", "0":
+		on = false
+	default:
+		on = true
+	}
+	return &on
+}
+
+// findIntPtr returns the first submatch of re in out parsed as a decimal
+// integer, or nil if re does not match.
+func findIntPtr(re *regexp.Regexp, out string) (*int64, error) {
+	m := re.FindStringSubmatch(out)
+	if m == nil {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// StatusInfo runs status and returns the parsed result.
+func (d *Drive) StatusInfo() (*StatusInfo, error) {
+	out, err := d.Status()
+	if err != nil {
+		return nil, err
+	}
+	return d.dialect().ParseStatus(out)
+}
+
+// Position returns the current file number, block number within the file
+// and partition from a single status. Each is -1 if mt reports it as
+// unknown or does not report it.
+func (d *Drive) Position() (file, block, partition int64, err error) {
+	info, err := d.StatusInfo()
+	if err != nil {
+		return 0, 0, 0, errors.Wrap(err, "position")
+	}
+	return info.FileNumber, info.BlockNumber, info.Partition, nil
+}
+
+// PartitionCount returns the number of partitions on the tape, 1 for an
+// unpartitioned tape, as parsed into StatusInfo.Partitions. mt-st and
+// FreeBSD mt do not print the count, so with their dialects this always
+// returns ErrUnsupportedOperation; only a custom Dialect whose ParseStatus
+// sets Partitions can report it.
+func (d *Drive) PartitionCount() (int, error) {
+	info, err := d.StatusInfo()
+	if err != nil {
+		return 0, errors.Wrap(err, "partition count")
+	}
+	if info.Partitions < 0 {
+		return 0, errors.Wrap(ErrUnsupportedOperation, "partition count: not reported in status")
+	}
+	return int(info.Partitions), nil
+}
+
+// Compression returns whether drive compression is enabled. Not all mt
+// versions report it in status; ErrUnsupportedOperation is returned when
+// it is not reported.
+func (d *Drive) Compression() (bool, error) {
+	info, err := d.StatusInfo()
+	if err != nil {
+		return false, errors.Wrap(err, "compression")
+	}
+	if info.Compression == nil {
+		return false, errors.Wrap(ErrUnsupportedOperation, "compression: not reported in status")
+	}
+	return *info.Compression, nil
+}
+
+// BlockSize returns the tape block size in bytes reported in status.
+// A block size of 0 means the drive is in variable block mode.
+func (d *Drive) BlockSize() (int64, error) {
+	info, err := d.StatusInfo()
+	if err != nil {
+		return 0, errors.Wrap(err, "block size")
+	}
+	if info.BlockSize < 0 {
+		return 0, errors.New("block size: block size not reported in status")
+	}
+	return info.BlockSize, nil
+}
+
+// IsOnline reports whether the drive is online, that is a tape is loaded
+// and ready. On Linux the SCSI device state is read from sysfs first, and
+// when the device is offline or blocked false is returned without running
+// mt. sysfs does not show whether a tape is loaded, so otherwise status
+// is run as before.
+func (d *Drive) IsOnline() (bool, error) {
+	if d.sysfsDeviceDown() {
+		return false, nil
+	}
+	b, err := d.StatusBits()
+	return b.Online, err
+}
+
+// WaitOnline polls status every poll interval until the drive is online
+// or ctx is done.
+func (d *Drive) WaitOnline(ctx context.Context, poll time.Duration) error {
+	return errors.Wrap(pollUntil(ctx, poll, d.IsOnline), "wait online")
+}
+
+// WaitReady polls status every poll interval until the drive is online
+// and the door is not open, or ctx is done. Status failing because the
+// device is busy or the drive is not ready is treated as not ready yet.
+func (d *Drive) WaitReady(ctx context.Context, poll time.Duration) error {
+	return errors.Wrap(pollUntil(ctx, poll, func() (bool, error) {
+		b, err := d.StatusBits()
+		if errors.Is(err, ErrDeviceBusy) || errors.Is(err, ErrDriveNotReady) {
+			return false, nil
+		}
+		return b.Online && !b.DrOpen, err
+	}), "wait ready")
+}
+
+// pollUntil calls done every poll interval until it returns true or an
+// error, or ctx is done.
+func pollUntil(ctx context.Context, poll time.Duration, done func() (bool, error)) error {
+	for {
+		ok, err := done()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
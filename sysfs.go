@@ -0,0 +1,114 @@
+package mt
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// sysfsTapeDir is the Linux sysfs class directory of SCSI tape devices.
+var sysfsTapeDir = "/sys/class/scsi_tape"
+
+// sysfsNode returns the sysfs directory of the Drive's device, found by
+// the name of the device file after resolving symlinks, e.g. nst0 for
+// /dev/tape/by-id/scsi-XXXX-nst. ErrUnsupportedOperation is returned if
+// there is none, such as on other systems or for non-SCSI tape devices.
+func (d *Drive) sysfsNode() (string, error) {
+	dev := d.devicePath()
+	if resolved, err := filepath.EvalSymlinks(dev); err == nil {
+		dev = resolved
+	}
+	node := filepath.Join(sysfsTapeDir, filepath.Base(dev))
+	if _, err := os.Stat(node); err != nil {
+		return "", errors.Wrapf(ErrUnsupportedOperation, "sysfs: no node for %s", d.Device)
+	}
+	return node, nil
+}
+
+// readSysfs returns the trimmed content of the sysfs attribute name.
+func readSysfs(node, name string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(node, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// readSysfsInt returns the sysfs attribute name as a decimal integer, -1
+// if it is missing.
+func readSysfsInt(node, name string) (int64, error) {
+	s, err := readSysfs(node, name)
+	if os.IsNotExist(err) {
+		return -1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// StatusFromSysfs returns the status fields the Linux st driver exposes
+// in /sys/class/scsi_tape without running mt. sysfs has no position or
+// general status bits, so the position fields are -1 and Bits is empty.
+// BlockSize, DensityCode and Compression are the driver defaults set with
+// defblksize, defdensity and defcompression, which are in effect when
+// defined; undefined defaults are left unknown. DriveType is the SCSI
+// vendor and model. ErrUnsupportedOperation is returned if the device has
+// no sysfs node.
+func (d *Drive) StatusFromSysfs() (*StatusInfo, error) {
+	node, err := d.sysfsNode()
+	if err != nil {
+		return nil, errors.Wrap(err, "sysfs status")
+	}
+	info := &StatusInfo{
+		FileNumber:  -1,
+		BlockNumber: -1,
+		Partition:   -1,
+		Partitions:  -1,
+		SoftErrors:  -1,
+	}
+	if info.BlockSize, err = readSysfsInt(node, "default_blksize"); err != nil {
+		return nil, errors.Wrap(err, "sysfs status: default_blksize")
+	}
+	// st prints a defined default density in hex, e.g. 0x58
+	density, err := readSysfsIntPtr(node, "default_density", 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "sysfs status: default_density")
+	}
+	info.DensityCode = -1
+	if density != nil {
+		info.DensityCode = *density
+	}
+	comp, err := readSysfsInt(node, "default_compression")
+	if err != nil {
+		return nil, errors.Wrap(err, "sysfs status: default_compression")
+	}
+	if comp >= 0 {
+		on := comp != 0
+		info.Compression = &on
+	}
+	vendor, _ := readSysfs(node, "device/vendor")
+	model, _ := readSysfs(node, "device/model")
+	info.DriveType = strings.TrimSpace(vendor + " " + model)
+	return info, nil
+}
+
+// sysfsDeviceDown reports whether sysfs shows the SCSI device of the
+// Drive in a state other than running, such as offline or blocked, in
+// which no command can reach the drive. It is false if there is no sysfs
+// node, or when the Drive has a Runner or DryRun set since mt then does
+// not talk to the device.
+func (d *Drive) sysfsDeviceDown() bool {
+	if d.Runner != nil || d.DryRun {
+		return false
+	}
+	node, err := d.sysfsNode()
+	if err != nil {
+		return false
+	}
+	state, err := readSysfs(node, "device/state")
+	return err == nil && state != "running"
+}
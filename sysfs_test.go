@@ -0,0 +1,61 @@
+package mt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSysfs points sysfsTapeDir at a temporary directory with a node for
+// nst0 holding attrs, and returns a Drive for it.
+func fakeSysfs(t *testing.T, attrs map[string]string) *Drive {
+	t.Helper()
+	dir := t.TempDir()
+	old := sysfsTapeDir
+	sysfsTapeDir = dir
+	t.Cleanup(func() { sysfsTapeDir = old })
+	for name, v := range attrs {
+		path := filepath.Join(dir, "nst0", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(v+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewDrive(filepath.Join(dir, "dev", "nst0"))
+}
+
+func TestStatusFromSysfsDensity(t *testing.T) {
+	tests := []struct {
+		density string
+		want    int64
+	}{
+		{"0x58", 0x58},
+		{"0x00", 0},
+		{"-1", -1},
+	}
+	for _, tt := range tests {
+		d := fakeSysfs(t, map[string]string{
+			"default_blksize":     "0",
+			"default_density":     tt.density,
+			"default_compression": "1",
+		})
+		info, err := d.StatusFromSysfs()
+		if err != nil {
+			t.Fatalf("%s: StatusFromSysfs: %v", tt.density, err)
+		}
+		if info.DensityCode != tt.want {
+			t.Errorf("%s: DensityCode = %#x, want %#x", tt.density, info.DensityCode, tt.want)
+		}
+	}
+
+	d := fakeSysfs(t, map[string]string{"default_blksize": "0"})
+	info, err := d.StatusFromSysfs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.DensityCode != -1 {
+		t.Errorf("missing default_density: DensityCode = %d, want -1", info.DensityCode)
+	}
+}
@@ -0,0 +1,316 @@
+package mt
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// tapeWriter writes to the tape device in records of the drive's block
+// size.
+type tapeWriter struct {
+	f *os.File
+	// blockSize is the fixed block size, 0 in variable block mode
+	blockSize int
+	// buf holds data short of a full block in fixed block mode
+	buf []byte
+}
+
+// Writer opens the Device for writing at the current tape position. The
+// block size is read from status: in variable block mode each Write is
+// written as one record, in fixed block mode data is written in whole
+// blocks and Close pads the last block with zeros. Close closes the device
+// without any positioning of its own; whether the drive rewinds on close
+// depends on the device used, e.g. /dev/st0 rewinds and /dev/nst0 does not.
+// The Drive is not locked while the Writer is open.
+func (d *Drive) Writer() (io.WriteCloser, error) {
+	w, err := d.openWriter()
+	if err != nil {
+		return nil, errors.Wrap(err, "writer")
+	}
+	return w, nil
+}
+
+func (d *Drive) openWriter() (*tapeWriter, error) {
+	// closing the device after writing writes a filemark, so writing
+	// is only allowed along with weof
+	if !d.allowed("weof") {
+		return nil, errors.Wrap(ErrUnsupportedOperation, "weof: not an allowed operation")
+	}
+	size, err := d.BlockSize()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(d.Device, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &tapeWriter{f: f, blockSize: int(size)}, nil
+}
+
+// variableWriteSize is the record size WriteFile uses in variable block
+// mode.
+const variableWriteSize = 64 << 10
+
+// WriteFile writes r to the tape at the current position as one file and
+// terminates it with a filemark. Data is written in records of the block
+// size, or 64 KiB records in variable block mode. The st driver writes a
+// filemark itself when the device is closed after writing; WriteFile only
+// writes one with weof if status shows the tape still in the file, as
+// when r was empty. A rewinding device such as /dev/st0 leaves the tape at BOT
+// after the close, where a filemark would overwrite the file, so then an
+// error is returned without writing one. ctx is checked between records,
+// and Progress is called after each. The number of bytes of r written is
+// returned.
+func (d *Drive) WriteFile(ctx context.Context, r io.Reader) (int64, error) {
+	before, err := d.fileNumber()
+	if err != nil {
+		return 0, errors.Wrap(err, "write file")
+	}
+	w, err := d.openWriter()
+	if err != nil {
+		return 0, errors.Wrap(err, "write file")
+	}
+	size := w.blockSize
+	if size == 0 {
+		size = variableWriteSize
+	}
+	written, err := copyRecords(ctx, w, r, make([]byte, size), d.Progress)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return written, errors.Wrap(err, "write file")
+	}
+	after, err := d.StatusInfo()
+	if err != nil {
+		return written, errors.Wrap(err, "write file")
+	}
+	switch {
+	case written > 0 && after.Bits.BOT:
+		return written, errors.New("write file: tape rewound on close, use a non-rewinding device")
+	case written > 0 && after.FileNumber == before+1 && after.BlockNumber == 0:
+		return written, nil
+	case after.FileNumber == before:
+		return written, errors.Wrap(d.WriteEOFMarks(1), "write file")
+	}
+	return written, errors.Wrapf(ErrVerifyFailed, "write file: at file %d block %d after writing at file %d",
+		after.FileNumber, after.BlockNumber, before)
+}
+
+// copyRecords copies r to w in writes of up to len(buf) bytes, filling
+// buf completely before each write except at the end of r. ctx is checked
+// before each write and progress, if not nil, is called after each.
+func copyRecords(ctx context.Context, w io.Writer, r io.Reader, buf []byte, progress func(int64)) (int64, error) {
+	var written int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		n, rerr := io.ReadFull(r, buf)
+		if n > 0 {
+			wn, err := w.Write(buf[:n])
+			written += int64(wn)
+			if err != nil {
+				return written, err
+			}
+			if progress != nil {
+				progress(written)
+			}
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			return written, nil
+		}
+		if rerr != nil {
+			return written, rerr
+		}
+	}
+}
+
+func (w *tapeWriter) Write(p []byte) (int, error) {
+	if w.blockSize == 0 {
+		return w.f.Write(p)
+	}
+	n := len(p)
+	if len(w.buf) > 0 {
+		fill := w.blockSize - len(w.buf)
+		if fill > len(p) {
+			fill = len(p)
+		}
+		w.buf = append(w.buf, p[:fill]...)
+		p = p[fill:]
+		if len(w.buf) < w.blockSize {
+			return n, nil
+		}
+		if _, err := w.f.Write(w.buf); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[:0]
+	}
+	whole := len(p) - len(p)%w.blockSize
+	if whole > 0 {
+		if wn, err := w.f.Write(p[:whole]); err != nil {
+			return n - len(p) + wn, err
+		}
+	}
+	w.buf = append(w.buf, p[whole:]...)
+	return n, nil
+}
+
+// Close pads and writes any partial block, then closes the device.
+func (w *tapeWriter) Close() error {
+	if len(w.buf) > 0 {
+		block := make([]byte, w.blockSize)
+		copy(block, w.buf)
+		w.buf = w.buf[:0]
+		if _, err := w.f.Write(block); err != nil {
+			w.f.Close()
+			return errors.Wrap(err, "write last block")
+		}
+	}
+	return w.f.Close()
+}
+
+// variableReadSize is the read buffer size in variable block mode, which
+// must be at least the largest record on the tape.
+const variableReadSize = 1 << 20
+
+// tapeReader reads from the tape device in records of the drive's block
+// size.
+type tapeReader struct {
+	f   *os.File
+	buf []byte
+	// data is the unread part of buf
+	data []byte
+	// fileMark is set after a read hit a filemark
+	fileMark bool
+}
+
+// Reader opens the Device for reading at the current tape position. Reads
+// are done in whole records: one record up to 1 MiB in variable block mode,
+// or whole blocks in fixed block mode, with data handed out as requested.
+// Read returns ErrFileMark at a filemark, after which reading continues
+// with the next file, and io.EOF at the end of data, that is a second
+// filemark or blank tape straight after a filemark. The Drive is not
+// locked while the Reader is open.
+func (d *Drive) Reader() (io.ReadCloser, error) {
+	r, err := d.openReader()
+	if err != nil {
+		return nil, errors.Wrap(err, "reader")
+	}
+	return r, nil
+}
+
+func (d *Drive) openReader() (*tapeReader, error) {
+	size, err := d.BlockSize()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(d.Device)
+	if err != nil {
+		return nil, err
+	}
+	bufSize := variableReadSize
+	if size > 0 {
+		bufSize = int(size) * (variableReadSize / int(size))
+		if bufSize == 0 {
+			bufSize = int(size)
+		}
+	}
+	return &tapeReader{f: f, buf: make([]byte, bufSize)}, nil
+}
+
+func (r *tapeReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// fill reads the next record into data.
+func (r *tapeReader) fill() error {
+	n, err := r.f.Read(r.buf)
+	if n == 0 && (err == nil || err == io.EOF) {
+		if r.fileMark {
+			return io.EOF
+		}
+		r.fileMark = true
+		return ErrFileMark
+	}
+	if err != nil && err != io.EOF {
+		return err
+	}
+	r.fileMark = false
+	r.data = r.buf[:n]
+	return nil
+}
+
+// Close closes the device.
+func (r *tapeReader) Close() error {
+	return r.f.Close()
+}
+
+// ReadFileTo copies the tape from the current position up to the next
+// filemark to w, returning the number of bytes copied. The tape is left
+// positioned after the filemark, at the start of the next file. Reads are
+// done in whole records as for Reader. If the end of data is reached
+// instead of a filemark io.EOF is returned with the bytes copied. ctx is
+// checked between records, and Progress is called after each.
+//
+// A read of nothing at the start of the call is either an empty file or
+// the end of data, as the filemark before it was read by the last call;
+// status is checked after closing the device and io.EOF returned if it
+// reports EOD.
+func (d *Drive) ReadFileTo(ctx context.Context, w io.Writer) (int64, error) {
+	r, err := d.openReader()
+	if err != nil {
+		return 0, errors.Wrap(err, "read file")
+	}
+	defer r.Close()
+	var copied int64
+	first := true
+	for {
+		if err := ctx.Err(); err != nil {
+			return copied, errors.Wrap(err, "read file")
+		}
+		if len(r.data) == 0 {
+			if err := r.fill(); err != nil {
+				if err == ErrFileMark && first {
+					// status cannot open the device while it is open
+					r.Close()
+					eod, serr := d.AtEOD()
+					if serr != nil {
+						return copied, errors.Wrap(serr, "read file")
+					}
+					if eod {
+						return copied, io.EOF
+					}
+					return copied, nil
+				}
+				if err == ErrFileMark {
+					return copied, nil
+				}
+				if err == io.EOF {
+					return copied, io.EOF
+				}
+				return copied, errors.Wrap(err, "read file")
+			}
+		}
+		first = false
+		n, err := w.Write(r.data)
+		copied += int64(n)
+		r.data = r.data[n:]
+		if err != nil {
+			return copied, errors.Wrap(err, "read file")
+		}
+		if d.Progress != nil {
+			d.Progress(copied)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package mt_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/benmcclelland/mt"
+	"github.com/benmcclelland/mt/mttest"
+	"github.com/pkg/errors"
+)
+
+func TestWriteFileRewindingDevice(t *testing.T) {
+	f, err := mttest.NewFakeMT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// a rewinding node reports BOT both before writing and after the close
+	if err := f.Set("status", mttest.Response{Stdout: mttest.StatusOnline}); err != nil {
+		t.Fatal(err)
+	}
+	d := f.Drive(filepath.Join(t.TempDir(), "st0"))
+	if err := os.WriteFile(d.Device, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	n, err := d.WriteFile(context.Background(), strings.NewReader("data"))
+	if err == nil {
+		t.Error("WriteFile on a rewinding device succeeded")
+	}
+	if n != 4 {
+		t.Errorf("WriteFile wrote %d bytes, want 4", n)
+	}
+	calls, err := f.Calls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range calls {
+		if c[2] == "weof" {
+			t.Errorf("WriteFile ran %q at BOT", c)
+		}
+	}
+}
+
+func TestWriteAllowedOps(t *testing.T) {
+	f := mttest.NewFakeRunner()
+	f.Set("status", mttest.Response{Stdout: "File number=1, block number=0, partition=0.\nTape block size 0 bytes.\n"})
+	d := f.Drive(filepath.Join(t.TempDir(), "nst0"))
+	if err := os.WriteFile(d.Device, []byte("file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d.AllowedOps = []string{"status", "fsf", "bsf"}
+	if _, err := d.Writer(); !errors.Is(err, mt.ErrUnsupportedOperation) {
+		t.Errorf("Writer without weof allowed = %v, want ErrUnsupportedOperation", err)
+	}
+	if _, err := d.WriteFile(context.Background(), strings.NewReader("data")); !errors.Is(err, mt.ErrUnsupportedOperation) {
+		t.Errorf("WriteFile without weof allowed = %v, want ErrUnsupportedOperation", err)
+	}
+	if b, err := os.ReadFile(d.Device); err != nil || string(b) != "file" {
+		t.Errorf("device holds %q, %v, want it unchanged", b, err)
+	}
+}
+
+func TestWriteFileMark(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		// after is the status after the close
+		after string
+		weof  bool
+	}{
+		{"driver wrote the filemark", "data", "File number=2, block number=0, partition=0.\n", false},
+		{"empty file", "", "File number=1, block number=0, partition=0.\n", true},
+	}
+	for _, tt := range tests {
+		f := mttest.NewFakeRunner()
+		f.Set("status", mttest.Response{Stdout: "File number=1, block number=0, partition=0.\n"},
+			mttest.Response{Stdout: "File number=1, block number=0, partition=0.\nTape block size 0 bytes.\n"},
+			mttest.Response{Stdout: tt.after})
+		device := filepath.Join(t.TempDir(), "nst0")
+		if err := os.WriteFile(device, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Drive(device).WriteFile(context.Background(), strings.NewReader(tt.data)); err != nil {
+			t.Errorf("%s: WriteFile: %v", tt.name, err)
+		}
+		weof := false
+		for _, c := range f.Calls() {
+			weof = weof || c[2] == "weof"
+		}
+		if weof != tt.weof {
+			t.Errorf("%s: ran weof %t, want %t", tt.name, weof, tt.weof)
+		}
+	}
+}
+
+// fifoTape returns a Drive reading files from a named pipe, one per open.
+// Each status run by the Drive answers with the next of statuses, and if
+// the status has a file, it is written to the next open of the pipe.
+func fifoTape(t *testing.T, statuses []struct{ out, file string }) *mt.Drive {
+	t.Helper()
+	device := filepath.Join(t.TempDir(), "nst0")
+	if err := syscall.Mkfifo(device, 0644); err != nil {
+		t.Skip(err)
+	}
+	files := make(chan string, len(statuses))
+	go func() {
+		for file := range files {
+			f, err := os.OpenFile(device, os.O_WRONLY, 0)
+			if err != nil {
+				return
+			}
+			io.WriteString(f, file)
+			f.Close()
+		}
+	}()
+	t.Cleanup(func() { close(files) })
+	d := mt.NewDrive(device)
+	d.Runner = mt.RunnerFunc(func(name string, args []string) ([]byte, error) {
+		if args[2] != "status" || len(statuses) == 0 {
+			return []byte{}, nil
+		}
+		s := statuses[0]
+		statuses = statuses[1:]
+		if s.file != "-" {
+			files <- s.file
+		}
+		return []byte(s.out), nil
+	})
+	return d
+}
+
+func TestReadFileToEOD(t *testing.T) {
+	const (
+		open = "File number=0, block number=0, partition=0.\nTape block size 0 bytes.\n"
+		more = "File number=2, block number=0, partition=0.\nGeneral status bits on (81010000):\n EOF ONLINE IM_REP_EN\n"
+		eod  = "File number=3, block number=0, partition=0.\nGeneral status bits on (09010000):\n EOD ONLINE IM_REP_EN\n"
+	)
+	// "-" marks the statuses run after an empty read, without an open
+	d := fifoTape(t, []struct{ out, file string }{
+		{open, "aaa"},
+		{open, ""},
+		{more, "-"},
+		{open, "bbb"},
+		{open, ""},
+		{eod, "-"},
+		{open, ""},
+		{eod, "-"},
+	})
+	ctx := context.Background()
+	for i, want := range []string{"aaa", "", "bbb"} {
+		var buf bytes.Buffer
+		if _, err := d.ReadFileTo(ctx, &buf); err != nil {
+			t.Fatalf("file %d: ReadFileTo: %v", i, err)
+		}
+		if buf.String() != want {
+			t.Errorf("file %d: read %q, want %q", i, buf.String(), want)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if n, err := d.ReadFileTo(ctx, io.Discard); n != 0 || err != io.EOF {
+			t.Errorf("ReadFileTo at EOD = %d, %v, want 0, io.EOF", n, err)
+		}
+	}
+}
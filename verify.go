@@ -0,0 +1,263 @@
+package mt
+
+import "github.com/pkg/errors"
+
+// fileNumber returns the current file number from status, or an error if
+// the drive does not report it.
+func (d *Drive) fileNumber() (int64, error) {
+	info, err := d.StatusInfo()
+	if err != nil {
+		return 0, err
+	}
+	if info.FileNumber < 0 {
+		return 0, errors.New("file number not known")
+	}
+	return info.FileNumber, nil
+}
+
+// WriteEOFMarksVerified writes n EOF marks at the current position, then
+// checks status that the file number advanced by n. ErrVerifyFailed is
+// returned if it did not.
+func (d *Drive) WriteEOFMarksVerified(n int64) error {
+	return d.writeEOFMarksChecked(n, "weof verify")
+}
+
+// writeEOFMarksChecked is WriteEOFMarksVerified with op naming the
+// caller in errors.
+func (d *Drive) writeEOFMarksChecked(n int64, op string) error {
+	before, err := d.fileNumber()
+	if err != nil {
+		return errors.Wrap(err, op)
+	}
+	if err := d.WriteEOFMarks(n); err != nil {
+		return err
+	}
+	after, err := d.fileNumber()
+	if err != nil {
+		return errors.Wrap(err, op)
+	}
+	if after != before+n {
+		return errors.Wrapf(ErrVerifyFailed, "weof: file number %d after writing %d marks at file %d",
+			after, n, before)
+	}
+	return nil
+}
+
+// SetBlockSizeVerified (SCSI tapes) set the block size to n bytes, then
+// checks status that the drive reports block size n, or variable block
+// mode when n is 0. ErrVerifyFailed is returned if it does not.
+func (d *Drive) SetBlockSizeVerified(n int64) error {
+	if err := d.SetBlockSize(n); err != nil {
+		return err
+	}
+	size, err := d.BlockSize()
+	if err != nil {
+		return errors.Wrap(err, "setblk verify")
+	}
+	if size != n {
+		return errors.Wrapf(ErrVerifyFailed, "setblk: drive reports block size %d after setting %d",
+			size, n)
+	}
+	return nil
+}
+
+// WriteFileMarksEnsured writes n EOF marks at the current position, then
+// checks status that the file number advanced by n, as
+// WriteEOFMarksVerified does. The two-fms driver option does not change
+// the expected advance: st only writes its second filemark when the
+// device is closed after writing data, not for marks written by weof.
+func (d *Drive) WriteFileMarksEnsured(n int64) error {
+	return d.writeEOFMarksChecked(n, "weof ensured")
+}
+
+// SetPartitionVerified (SCSI tapes) switch to the nth partition, then
+// checks status that the drive reports partition n. ErrVerifyFailed is
+// returned if it does not, for example when partition support is not
+// enabled for the drive and it stayed on partition 0.
+func (d *Drive) SetPartitionVerified(n int64) error {
+	if err := d.SetPartition(n); err != nil {
+		return err
+	}
+	info, err := d.StatusInfo()
+	if err != nil {
+		return errors.Wrap(err, "setpartition verify")
+	}
+	if info.Partition != n {
+		return errors.Wrapf(ErrVerifyFailed, "setpartition: drive reports partition %d after setting %d",
+			info.Partition, n)
+	}
+	return nil
+}
+
+// SeekPartitionVerified (SCSI tapes) seek to the nth block in partition
+// part, then checks with status and tell that the drive reports partition
+// part and block n. ErrVerifyFailed is returned if it does not.
+func (d *Drive) SeekPartitionVerified(n, part int64) error {
+	if err := d.SeekPartition(n, part); err != nil {
+		return err
+	}
+	info, err := d.StatusInfo()
+	if err != nil {
+		return errors.Wrap(err, "partseek verify")
+	}
+	if info.Partition != part {
+		return errors.Wrapf(ErrVerifyFailed, "partseek: drive reports partition %d after seeking to %d",
+			info.Partition, part)
+	}
+	block, err := d.TellBlock()
+	if err != nil {
+		return errors.Wrap(err, "partseek verify")
+	}
+	if block != n {
+		return errors.Wrapf(ErrVerifyFailed, "partseek: drive reports block %d after seeking to %d",
+			block, n)
+	}
+	return nil
+}
+
+// SetDensityByNameVerified (SCSI tapes) set the tape density to the code
+// for name in Densities, then checks status that the drive reports that
+// code. ErrVerifyFailed is returned if it reports a different density.
+func (d *Drive) SetDensityByNameVerified(name string) error {
+	if err := d.SetDensityByName(name); err != nil {
+		return err
+	}
+	want, _ := DensityCodeByName(name)
+	code, got, err := d.Density()
+	if err != nil {
+		return errors.Wrap(err, "setdensity verify")
+	}
+	if code != want {
+		return errors.Wrapf(ErrVerifyFailed, "setdensity: drive reports density %s after setting %s",
+			got, DensityName(want))
+	}
+	return nil
+}
+
+// SeekTapeVerified (SCSI tapes) seek to the nth block on the tape, then
+// checks with tell that the drive reports block n. Seek and tell use the
+// same addressing, device dependent or SCSI-2 logical as selected by the
+// scsi2logical option, so the check holds in either mode.
+// ErrVerifyFailed is returned if the drive reports a different block.
+func (d *Drive) SeekTapeVerified(n int64) error {
+	if err := d.SeekTape(n); err != nil {
+		return err
+	}
+	block, err := d.TellBlock()
+	if err != nil {
+		return errors.Wrap(err, "seek verify")
+	}
+	if block != n {
+		return errors.Wrapf(ErrVerifyFailed, "seek: drive reports block %d after seeking to %d",
+			block, n)
+	}
+	return nil
+}
+
+// WriteSetMarksVerified (SCSI tapes) write n setmarks at the current
+// position, then checks with tell that the block address advanced by n,
+// each setmark taking one position like a filemark does. Setmarks are
+// only written when the dialect supports them and Capabilities reports
+// the drive has them, otherwise ErrUnsupportedOperation is returned
+// without writing. ErrVerifyFailed is returned if the address did not
+// advance by n.
+func (d *Drive) WriteSetMarksVerified(n int64) error {
+	if !d.Supports("wset") {
+		return errors.Wrapf(ErrUnsupportedOperation, "wset: %s mt", d.dialect().Name)
+	}
+	c, err := d.Capabilities()
+	if err != nil {
+		return errors.Wrap(err, "wset verify")
+	}
+	if !c.HasSetMarks {
+		return errors.Wrap(ErrUnsupportedOperation, "wset: drive does not report set marks")
+	}
+	before, err := d.TellBlock()
+	if err != nil {
+		return errors.Wrap(err, "wset verify")
+	}
+	if err := d.WriteSetMarks(n); err != nil {
+		return err
+	}
+	after, err := d.TellBlock()
+	if err != nil {
+		return errors.Wrap(err, "wset verify")
+	}
+	if after != before+n {
+		return errors.Wrapf(ErrVerifyFailed, "wset: block %d after writing %d setmarks at block %d",
+			after, n, before)
+	}
+	return nil
+}
+
+// MakePartitionVerified (SCSI tapes) format the tape with one (n is zero)
+// or two partitions (n gives the size of the second partition in
+// megabytes), then checks with PartitionCount that the tape has that many
+// partitions. ErrVerifyFailed is returned if it does not. Status is
+// checked before formatting, and ErrUnsupportedOperation is returned
+// without touching the tape if it does not report the partition count,
+// since the format then cannot be confirmed. mt-st and FreeBSD mt never
+// report it, see PartitionCount, so with their dialects this always
+// returns ErrUnsupportedOperation.
+func (d *Drive) MakePartitionVerified(n int64) error {
+	info, err := d.StatusInfo()
+	if err != nil {
+		return errors.Wrap(err, "mkpartition verify")
+	}
+	if info.Partitions < 0 {
+		return errors.Wrap(ErrUnsupportedOperation, "mkpartition verify: partition count not reported")
+	}
+	if err := d.MakePartition(n); err != nil {
+		return err
+	}
+	want := 1
+	if n > 0 {
+		want = 2
+	}
+	got, err := d.PartitionCount()
+	if err != nil {
+		return errors.Wrap(err, "mkpartition verify")
+	}
+	if got != want {
+		return errors.Wrapf(ErrVerifyFailed, "mkpartition: tape has %d partitions after formatting for %d",
+			got, want)
+	}
+	return nil
+}
+
+// SeekToAppend positions the tape at the end of data, ready to append a
+// file, then checks it got there: either status shows the EOD or EOT bit
+// or, for drives that do not report EOD, spacing forward one file fails
+// and leaves the known file and block number unchanged. If that space
+// succeeds the tape was short of the end of data and has been moved on
+// a file. A failed space cannot tell end of data from other errors, such
+// as a medium error before the last file, so on drives without the EOD
+// bit the check is only that mt could go no further. ErrVerifyFailed is
+// returned if the position was not confirmed.
+func (d *Drive) SeekToAppend() error {
+	if err := d.PositionEOD(); err != nil {
+		return err
+	}
+	info, err := d.StatusInfo()
+	if err != nil {
+		return errors.Wrap(err, "eod verify")
+	}
+	if info.Bits.EOD || info.Bits.EOT {
+		return nil
+	}
+	if info.FileNumber < 0 {
+		return errors.Wrap(ErrVerifyFailed, "eod: no EOD bit and file number not known")
+	}
+	if err := d.NextFile(); err == nil {
+		return errors.Wrapf(ErrVerifyFailed, "eod: spaced past file %d, not at end of data", info.FileNumber)
+	}
+	again, err := d.StatusInfo()
+	if err != nil {
+		return errors.Wrap(err, "eod verify")
+	}
+	if again.FileNumber != info.FileNumber || again.BlockNumber != info.BlockNumber {
+		return errors.Wrapf(ErrVerifyFailed, "eod: position moved, file %d block %d then file %d block %d",
+			info.FileNumber, info.BlockNumber, again.FileNumber, again.BlockNumber)
+	}
+	return nil
+}